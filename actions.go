@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+// the remediation actions which can be used in remediation.yaml
+const (
+	actionMute           = "sumologic-mute"
+	actionUnmute         = "sumologic-unmute"
+	actionDisableMonitor = "sumologic-disable-monitor"
+	actionEnableMonitor  = "sumologic-enable-monitor"
+	actionResolveAlert   = "sumologic-resolve-alert"
+
+	defaultMuteDuration = 1 * time.Hour
+	actionTimeout       = 2 * time.Minute
+
+	// the labels set by the webhook for the remediations triggered by Sumo Logic alerts
+	monitorIdLabel = "sumologicMonitorId"
+	alertURLLabel  = "sumologicAlert"
+)
+
+// actionParams is the value of an action in remediation.yaml, e.g.,
+//
+//	actionsOnOpen:
+//	  - action: sumologic-mute
+//	    name: Mute the monitor of the alert
+//	    value:
+//	      id: 0000000000A1B2C3
+//	      duration: 30m
+//
+// The value can also be just the id. Without an id, the action uses the monitor or the alert
+// of the remediation if it was triggered by a Sumo Logic alert.
+type actionParams struct {
+	// Id is the id of the monitor (or folder for sumologic-mute) or the alert
+	Id string `json:"id"`
+	// Duration is how long the monitor is muted (Go duration, sumologic-mute only)
+	Duration string `json:"duration"`
+	// MutingScheduleId is the muting schedule created by sumologic-mute (sumologic-unmute only)
+	MutingScheduleId string `json:"mutingScheduleId"`
+
+	// Query is the scope of the log search (sumologic-log-snapshot only, placeholders are replaced)
+	Query string `json:"query"`
+	// Mode is either logreduce or count (sumologic-log-snapshot only)
+	Mode string `json:"mode"`
+	// Window is how far before the alert (or now) the logs are searched (Go duration, sumologic-log-snapshot only)
+	Window string `json:"window"`
+	// Limit is the number of top errors in the summary (sumologic-log-snapshot only)
+	Limit int `json:"limit"`
+}
+
+// actionFinishedEventData is the action.finished event with the triggered action and what was changed in Sumo Logic
+type actionFinishedEventData struct {
+	keptnv2.EventData
+	Action    keptnv2.ActionInfo `json:"action"`
+	SumoLogic actionResult       `json:"sumologic"`
+}
+
+// actionResult is what an action changed in Sumo Logic
+type actionResult struct {
+	Action   string `json:"action"`
+	TargetId string `json:"targetId,omitempty"`
+	// Changes are the details of the change, e.g., the id of the muting schedule
+	Changes map[string]string `json:"changes,omitempty"`
+	// Undo is the action which reverts the change (if it can be reverted)
+	Undo *keptnv2.ActionInfo `json:"undo,omitempty"`
+	// Snapshot is the summary of the errors in the logs (sumologic-log-snapshot only)
+	Snapshot *logSnapshot `json:"snapshot,omitempty"`
+}
+
+// actionRunner runs the remediation actions against the Sumo Logic APIs
+type actionRunner struct {
+	monitors *monitorsClient
+	search   *searchJobClient
+	// uiURL is the URL of the Sumo Logic UI for the links (e.g., https://service.eu.sumologic.com)
+	uiURL string
+	// logSnapshotQuery is the default scope of the log search of sumologic-log-snapshot
+	logSnapshotQuery string
+	now              func() time.Time
+}
+
+// isSumoAction returns true for the actions handled by this service
+func isSumoAction(action string) bool {
+	switch action {
+	case actionMute, actionUnmute, actionDisableMonitor, actionEnableMonitor, actionResolveAlert, actionLogSnapshot:
+		return true
+	}
+	return false
+}
+
+// parseActionParams reads the value of the action, which is either just the id or an object
+func parseActionParams(value interface{}) (actionParams, error) {
+	params := actionParams{}
+	switch v := value.(type) {
+	case nil:
+	case string:
+		params.Id = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return params, err
+		}
+		if err := json.Unmarshal(b, &params); err != nil {
+			return params, fmt.Errorf("invalid action value: %w", err)
+		}
+	}
+	params.Id = strings.TrimSpace(params.Id)
+	return params, nil
+}
+
+// alertIdFromURL returns the id of the alert in the alert URL of the webhook,
+// e.g., `0000000000ABCDEF` for https://service.sumologic.com/ui/#/alert/0000000000ABCDEF
+func alertIdFromURL(alertURL string) string {
+	i := strings.LastIndex(alertURL, "/alert/")
+	if i < 0 {
+		return ""
+	}
+	id := alertURL[i+len("/alert/"):]
+	if j := strings.IndexAny(id, "/?#"); j >= 0 {
+		id = id[:j]
+	}
+	return id
+}
+
+// run runs the action and returns what was changed
+func (r *actionRunner) run(ctx context.Context, data *keptnv2.ActionTriggeredEventData) (actionResult, error) {
+	result := actionResult{Action: data.Action.Action}
+
+	params, err := parseActionParams(data.Action.Value)
+	if err != nil {
+		return result, err
+	}
+
+	// the log snapshot only reads the logs of the service
+	if data.Action.Action == actionLogSnapshot {
+		result.Snapshot, err = r.logSnapshot(ctx, data, params)
+		if err != nil {
+			return result, fmt.Errorf("failed to search the logs: %w", err)
+		}
+		return result, nil
+	}
+
+	targetId := params.Id
+	if targetId == "" {
+		if data.Action.Action == actionResolveAlert {
+			targetId = alertIdFromURL(data.Labels[alertURLLabel])
+		} else {
+			targetId = data.Labels[monitorIdLabel]
+		}
+	}
+	if targetId == "" && data.Action.Action != actionUnmute {
+		return result, errors.New("the action needs an `id` in its value (or a remediation triggered by a Sumo Logic alert)")
+	}
+	result.TargetId = targetId
+
+	switch data.Action.Action {
+	case actionMute:
+		duration := defaultMuteDuration
+		if params.Duration != "" {
+			duration, err = time.ParseDuration(params.Duration)
+			if err != nil || duration < time.Minute {
+				return result, fmt.Errorf("invalid duration `%s` (should be a Go duration of at least 1m, e.g., `30m`)", params.Duration)
+			}
+		}
+
+		start := r.now()
+		name := fmt.Sprintf("Keptn %s/%s/%s: %s", data.Project, data.Stage, data.Service, data.Action.Name)
+		description := fmt.Sprintf("Created by the Keptn remediation of %s (%s)", data.Service, data.Problem.ProblemTitle)
+		id, err := r.monitors.muteMonitors(ctx, name, description, []string{targetId}, start, duration)
+		if err != nil {
+			return result, fmt.Errorf("failed to mute %s: %w", targetId, err)
+		}
+
+		result.Changes = map[string]string{
+			"mutingScheduleId": id,
+			"mutedUntil":       start.Add(duration).UTC().Format(time.RFC3339),
+		}
+		result.Undo = &keptnv2.ActionInfo{Action: actionUnmute, Value: map[string]string{"mutingScheduleId": id}}
+
+	case actionUnmute:
+		if params.MutingScheduleId == "" {
+			return result, errors.New("the action needs the `mutingScheduleId` created by " + actionMute)
+		}
+		if err := r.monitors.unmuteMonitors(ctx, params.MutingScheduleId); err != nil {
+			return result, fmt.Errorf("failed to delete the muting schedule %s: %w", params.MutingScheduleId, err)
+		}
+		result.TargetId = params.MutingScheduleId
+		result.Changes = map[string]string{"deletedMutingScheduleId": params.MutingScheduleId}
+
+	case actionDisableMonitor, actionEnableMonitor:
+		disable := data.Action.Action == actionDisableMonitor
+		wasDisabled, err := r.monitors.setMonitorDisabled(ctx, targetId, disable)
+		if err != nil {
+			return result, fmt.Errorf("failed to update the monitor %s: %w", targetId, err)
+		}
+
+		result.Changes = map[string]string{
+			"wasDisabled": fmt.Sprint(wasDisabled),
+			"isDisabled":  fmt.Sprint(disable),
+		}
+		// only a change of the state can be undone
+		if wasDisabled != disable {
+			undo := actionEnableMonitor
+			if wasDisabled {
+				undo = actionDisableMonitor
+			}
+			result.Undo = &keptnv2.ActionInfo{Action: undo, Value: targetId}
+		}
+
+	case actionResolveAlert:
+		if err := r.monitors.resolveAlert(ctx, targetId); err != nil {
+			return result, fmt.Errorf("failed to resolve the alert %s: %w", targetId, err)
+		}
+		result.Changes = map[string]string{"resolvedAlertId": targetId}
+
+	default:
+		return result, fmt.Errorf("unknown action %s", data.Action.Action)
+	}
+
+	return result, nil
+}
+
+// message describes the change for the action.finished event
+func (r actionResult) message() string {
+	switch r.Action {
+	case actionMute:
+		return fmt.Sprintf("muted %s until %s (muting schedule %s)", r.TargetId, r.Changes["mutedUntil"], r.Changes["mutingScheduleId"])
+	case actionUnmute:
+		return fmt.Sprintf("deleted the muting schedule %s", r.TargetId)
+	case actionDisableMonitor:
+		return fmt.Sprintf("disabled the monitor %s", r.TargetId)
+	case actionEnableMonitor:
+		return fmt.Sprintf("enabled the monitor %s", r.TargetId)
+	case actionResolveAlert:
+		return fmt.Sprintf("resolved the alert %s", r.TargetId)
+	case actionLogSnapshot:
+		if r.Snapshot != nil {
+			return r.Snapshot.message()
+		}
+	}
+	return ""
+}
+
+// labels are added to the labels of the action.finished event
+func (r actionResult) labels() map[string]string {
+	if r.Snapshot != nil {
+		return r.Snapshot.labels()
+	}
+	return nil
+}
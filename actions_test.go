@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+// fakeActionsAPI records the requests of the actions and has a single disabled monitor
+type fakeActionsAPI struct {
+	requests []string
+	bodies   []map[string]interface{}
+}
+
+func (f *fakeActionsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	body := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.bodies = append(f.bodies, body)
+
+	switch r.URL.Path {
+	case "/v1/mutingSchedules/root":
+		json.NewEncoder(w).Encode(monitorsLibraryItem{Id: "muting-root"})
+	case "/v1/mutingSchedules":
+		json.NewEncoder(w).Encode(mutingSchedule{Id: "schedule-1"})
+	case "/v1/monitors/monitor-1":
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "monitor-1", "type": monitorsLibraryMonitorType, "version": 3, "isDisabled": true, "monitorType": "Metrics", "createdAt": "2022-05-01T00:00:00Z", "modifiedBy": "000000000000ABCD"})
+	}
+}
+
+func TestActionRunner(t *testing.T) {
+	api := &fakeActionsAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	runner := &actionRunner{monitors: newMonitorsClient(server.URL, "id", "key"), now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	// the monitor of the alert is muted
+	data := &keptnv2.ActionTriggeredEventData{
+		EventData: keptnv2.EventData{Labels: map[string]string{monitorIdLabel: "monitor-1", alertURLLabel: "https://service.sumologic.com/ui/#/alert/alert-1"}},
+		Action:    keptnv2.ActionInfo{Action: actionMute, Value: map[string]interface{}{"duration": "30m"}},
+	}
+	result, err := runner.run(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TargetId != "monitor-1" || result.Changes["mutingScheduleId"] != "schedule-1" || result.Changes["mutedUntil"] != "2022-06-01T12:30:00Z" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.Undo == nil || result.Undo.Action != actionUnmute {
+		t.Errorf("Expected the muting to be undone by %s, but got %+v", actionUnmute, result.Undo)
+	}
+	schedule := api.bodies[1]["schedule"].(map[string]interface{})
+	if api.requests[1] != "POST /v1/mutingSchedules" || schedule["duration"] != float64(30) || schedule["startTime"] != "12:00" {
+		t.Errorf("Unexpected muting schedule %v", api.bodies[1])
+	}
+
+	// enabling keeps the other fields of the monitor (except the read-only ones)
+	api.requests = nil
+	api.bodies = nil
+	data.Action = keptnv2.ActionInfo{Action: actionEnableMonitor}
+	result, err = runner.run(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.requests[1] != "PUT /v1/monitors/monitor-1" || api.bodies[1]["isDisabled"] != false || api.bodies[1]["version"] != float64(3) || api.bodies[1]["monitorType"] != "Metrics" {
+		t.Errorf("Unexpected update of the monitor %v %v", api.requests, api.bodies)
+	}
+	if api.bodies[1]["type"] != monitorsLibraryMonitorUpdateType {
+		t.Errorf("Expected the monitor to be updated with a %s, but got %v", monitorsLibraryMonitorUpdateType, api.bodies[1]["type"])
+	}
+	for _, field := range []string{"id", "createdAt", "modifiedBy"} {
+		if _, ok := api.bodies[1][field]; ok {
+			t.Errorf("Expected the read-only field %s not to be sent, but got %v", field, api.bodies[1])
+		}
+	}
+	if result.Undo == nil || result.Undo.Action != actionDisableMonitor || result.Undo.Value != "monitor-1" {
+		t.Errorf("Expected the monitor to be disabled again to undo, but got %+v", result.Undo)
+	}
+
+	// the alert id comes from the alert URL
+	api.requests = nil
+	data.Action = keptnv2.ActionInfo{Action: actionResolveAlert}
+	if _, err = runner.run(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	if api.requests[0] != "POST /v1/alerts/alert-1/resolve" {
+		t.Errorf("Expected the alert to be resolved, but got %v", api.requests)
+	}
+
+	data.Labels = nil
+	if _, err = runner.run(ctx, data); err == nil {
+		t.Errorf("Expected an error without an alert id")
+	}
+}
+
+func TestParseActionParams(t *testing.T) {
+	params, err := parseActionParams(" monitor-1 ")
+	if err != nil || params.Id != "monitor-1" {
+		t.Errorf("Expected the value to be the id, but got %+v (%v)", params, err)
+	}
+
+	params, err = parseActionParams(map[string]interface{}{"id": "folder-1", "duration": "2h"})
+	if err != nil || params.Id != "folder-1" || params.Duration != "2h" {
+		t.Errorf("Unexpected params %+v (%v)", params, err)
+	}
+
+	if _, err = parseActionParams(map[string]interface{}{"id": 1}); err == nil {
+		t.Errorf("Expected an error for a numeric id")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+	"github.com/keptn-sandbox/sumologic-service/pkg/metricsquery"
+)
+
+// clientOperatorNames are the operators the service emulates
+var clientOperatorNames = map[string]bool{
+	"fillmissing": true,
+	"outlier":     true,
+}
+
+// clientOperator emulates a metrics operator the Metrics Query API doesn't support
+// by applying it to the time series returned by the API
+type clientOperator interface {
+	apply(timeSeries []types.TimeSeries, from, to time.Time) []types.TimeSeries
+}
+
+// newClientOperator creates the emulation of the operator
+func newClientOperator(op metricsquery.Operator, processed processedQuery) (clientOperator, error) {
+	switch op.Name {
+	case "fillmissing":
+		fill, err := metricsquery.ParseFillMissing(op)
+		if err != nil {
+			return nil, err
+		}
+		if processed.Quantization == 0 {
+			return nil, &metricsquery.SyntaxError{Pos: op.Pos, Msg: "`fillmissing` needs `quantize` to know where the points are missing"}
+		}
+		return fillMissingOperator{fill: fill, interval: time.Duration(processed.Quantization) * time.Millisecond}, nil
+	case "outlier":
+		outlier, err := metricsquery.ParseOutlier(op)
+		if err != nil {
+			return nil, err
+		}
+		return outlierOperator{outlier: outlier}, nil
+	}
+	return nil, &metricsquery.SyntaxError{Pos: op.Pos, Msg: fmt.Sprintf("`%s` is not supported", op.Name)}
+}
+
+// fillMissingOperator fills the gaps in the time series on the quantization grid
+type fillMissingOperator struct {
+	fill     metricsquery.FillMissing
+	interval time.Duration
+}
+
+func (o fillMissingOperator) apply(timeSeries []types.TimeSeries, from, to time.Time) []types.TimeSeries {
+	filled := make([]types.TimeSeries, 0, len(timeSeries))
+	for _, ts := range timeSeries {
+		points := &types.Points{}
+		if ts.Points != nil {
+			points = ts.Points
+		}
+		filled = append(filled, types.TimeSeries{
+			MetricDefinition: ts.MetricDefinition,
+			Points:           o.fillPoints(points, from, to),
+		})
+	}
+	return filled
+}
+
+// fillPoints puts a point on every step of the quantization grid between from and to.
+// The grid is aligned with the existing points because Sumo Logic aligns the quantization buckets.
+func (o fillMissingOperator) fillPoints(points *types.Points, from, to time.Time) *types.Points {
+	step := o.interval.Milliseconds()
+	if step <= 0 {
+		return points
+	}
+
+	known := map[int64]float64{}
+	for i, t := range points.Timestamps {
+		if i < len(points.Values) {
+			known[t] = points.Values[i]
+		}
+	}
+
+	// align the grid with the existing points
+	first := from.UnixMilli()
+	if len(points.Timestamps) > 0 {
+		first += ((points.Timestamps[0]-first)%step + step) % step
+	}
+
+	grid := []int64{}
+	for t := first; t < to.UnixMilli(); t += step {
+		grid = append(grid, t)
+	}
+	// keep the existing points which are not on the grid
+	for t := range known {
+		if (t-first)%step != 0 || t < first || t >= to.UnixMilli() {
+			grid = append(grid, t)
+		}
+	}
+	sort.Slice(grid, func(i, j int) bool { return grid[i] < grid[j] })
+
+	values := make([]float64, len(grid))
+	present := make([]bool, len(grid))
+	for i, t := range grid {
+		values[i], present[i] = known[t]
+	}
+
+	result := &types.Points{Timestamps: []int64{}, Values: []float64{}}
+	for i, t := range grid {
+		value, ok := values[i], present[i]
+		if !ok {
+			value, ok = o.fillValue(grid, values, present, i)
+		}
+		if ok {
+			result.Timestamps = append(result.Timestamps, t)
+			result.Values = append(result.Values, value)
+		}
+	}
+	return result
+}
+
+// fillValue returns the value for the missing point i (false if the strategy has no value for it)
+func (o fillMissingOperator) fillValue(grid []int64, values []float64, present []bool, i int) (float64, bool) {
+	prev, next := -1, -1
+	for j := i - 1; j >= 0; j-- {
+		if present[j] {
+			prev = j
+			break
+		}
+	}
+	for j := i + 1; j < len(grid); j++ {
+		if present[j] {
+			next = j
+			break
+		}
+	}
+
+	switch o.fill.Strategy {
+	case metricsquery.FillConstant:
+		return o.fill.Value, true
+	case metricsquery.FillLast:
+		if prev >= 0 {
+			return values[prev], true
+		}
+	case metricsquery.FillNext:
+		if next >= 0 {
+			return values[next], true
+		}
+	case metricsquery.FillInterpolation:
+		if prev >= 0 && next >= 0 {
+			ratio := float64(grid[i]-grid[prev]) / float64(grid[next]-grid[prev])
+			return values[prev] + (values[next]-values[prev])*ratio, true
+		}
+	}
+	return 0, false
+}
+
+// outlierOperator replaces every point with 1 if it is an outlier and 0 otherwise,
+// so the `sum` reducer returns the number of outliers and `avg` the fraction of points that are outliers.
+// A point is an outlier when it is more than threshold standard deviations away from the
+// moving average of the window points before it (for consecutive points in a row).
+// The first window points of a time series are dropped since there is nothing to compare them to.
+type outlierOperator struct {
+	outlier metricsquery.Outlier
+}
+
+func (o outlierOperator) apply(timeSeries []types.TimeSeries, from, to time.Time) []types.TimeSeries {
+	flagged := make([]types.TimeSeries, 0, len(timeSeries))
+	for _, ts := range timeSeries {
+		points := &types.Points{Timestamps: []int64{}, Values: []float64{}}
+		if ts.Points != nil {
+			points = o.flagPoints(ts.Points)
+		}
+		flagged = append(flagged, types.TimeSeries{
+			MetricDefinition: ts.MetricDefinition,
+			Points:           points,
+		})
+	}
+	return flagged
+}
+
+func (o outlierOperator) flagPoints(points *types.Points) *types.Points {
+	window := o.outlier.Window
+	result := &types.Points{Timestamps: []int64{}, Values: []float64{}}
+
+	streak := 0
+	for i := window; i < len(points.Values) && i < len(points.Timestamps); i++ {
+		mean, stddev := meanAndStddev(points.Values[i-window : i])
+		deviation := points.Values[i] - mean
+
+		deviates := false
+		switch o.outlier.Direction {
+		case metricsquery.OutlierUp:
+			deviates = deviation > o.outlier.Threshold*stddev
+		case metricsquery.OutlierDown:
+			deviates = -deviation > o.outlier.Threshold*stddev
+		default:
+			deviates = math.Abs(deviation) > o.outlier.Threshold*stddev
+		}
+
+		if deviates {
+			streak++
+		} else {
+			streak = 0
+		}
+
+		value := 0.0
+		if streak >= o.outlier.Consecutive {
+			value = 1
+		}
+		result.Timestamps = append(result.Timestamps, points.Timestamps[i])
+		result.Values = append(result.Values, value)
+	}
+	return result
+}
+
+// meanAndStddev returns the mean and the (population) standard deviation of the values
+func meanAndStddev(values []float64) (float64, float64) {
+	mean := sum(values) / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+	"github.com/keptn-sandbox/sumologic-service/pkg/metricsquery"
+)
+
+func TestFillMissingOperator(t *testing.T) {
+	from := time.UnixMilli(0)
+	to := time.UnixMilli(5 * 60000)
+
+	// points at minutes 1 and 3 are missing
+	timeSeries := []types.TimeSeries{
+		{Points: &types.Points{Timestamps: []int64{0, 2 * 60000, 4 * 60000}, Values: []float64{10, 20, 40}}},
+	}
+
+	tests := []struct {
+		fill metricsquery.FillMissing
+		want []float64
+	}{
+		{metricsquery.FillMissing{Strategy: metricsquery.FillConstant}, []float64{10, 0, 20, 0, 40}},
+		{metricsquery.FillMissing{Strategy: metricsquery.FillConstant, Value: -1}, []float64{10, -1, 20, -1, 40}},
+		{metricsquery.FillMissing{Strategy: metricsquery.FillLast}, []float64{10, 10, 20, 20, 40}},
+		{metricsquery.FillMissing{Strategy: metricsquery.FillNext}, []float64{10, 20, 20, 40, 40}},
+		{metricsquery.FillMissing{Strategy: metricsquery.FillInterpolation}, []float64{10, 15, 20, 30, 40}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fill.Strategy, func(t *testing.T) {
+			op := fillMissingOperator{fill: tt.fill, interval: time.Minute}
+			filled := op.apply(timeSeries, from, to)
+			if !reflect.DeepEqual(filled[0].Points.Values, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, filled[0].Points.Values)
+			}
+			if len(filled[0].Points.Timestamps) != len(tt.want) {
+				t.Errorf("Expected a timestamp for every value, but got %v", filled[0].Points.Timestamps)
+			}
+		})
+	}
+}
+
+func TestProcessQueryWithFillMissing(t *testing.T) {
+	processed, err := processQuery("metric=cpu | quantize to 1m using avg | sum | fillmissing using last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.Query != "metric=cpu | sum" || len(processed.ClientOperators) != 1 {
+		t.Errorf("Expected fillmissing to be taken out of the query, but got %+v", processed)
+	}
+
+	for _, query := range []string{
+		"metric=cpu | fillmissing using zero",
+		"metric=cpu | quantize to 1m | fillmissing using zero | sum",
+		"metric=cpu | quantize to 1m | fillmissing using sometimes",
+	} {
+		if _, err := processQuery(query); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
+
+func TestOutlierOperator(t *testing.T) {
+	tests := []struct {
+		name    string
+		outlier metricsquery.Outlier
+		values  []float64
+		want    []float64
+	}{
+		{"both directions", metricsquery.Outlier{Window: 2, Threshold: 1, Consecutive: 1, Direction: metricsquery.OutlierBoth}, []float64{10, 10, 30, 10, 10, 0, 0, 10}, []float64{1, 0, 0, 1, 0, 1}},
+		{"up only", metricsquery.Outlier{Window: 2, Threshold: 1, Consecutive: 1, Direction: metricsquery.OutlierUp}, []float64{10, 10, 30, 10, 10, 0, 0, 10}, []float64{1, 0, 0, 0, 0, 1}},
+		{"down only", metricsquery.Outlier{Window: 2, Threshold: 1, Consecutive: 1, Direction: metricsquery.OutlierDown}, []float64{10, 10, 30, 10, 10, 0, 0, 10}, []float64{0, 0, 0, 1, 0, 0}},
+		{"consecutive", metricsquery.Outlier{Window: 2, Threshold: 1, Consecutive: 2, Direction: metricsquery.OutlierBoth}, []float64{10, 10, 30, 80, 10}, []float64{0, 1, 1}},
+		{"too few points", metricsquery.Outlier{Window: 5, Threshold: 3, Consecutive: 1, Direction: metricsquery.OutlierBoth}, []float64{10, 10, 30}, []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamps := make([]int64, len(tt.values))
+			for i := range timestamps {
+				timestamps[i] = int64(i) * 60000
+			}
+
+			op := outlierOperator{outlier: tt.outlier}
+			flagged := op.apply([]types.TimeSeries{{Points: &types.Points{Timestamps: timestamps, Values: tt.values}}}, time.Time{}, time.Time{})
+			if !reflect.DeepEqual(flagged[0].Points.Values, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, flagged[0].Points.Values)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/keptn-sandbox/sumologic-service/pkg/metricsquery"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+// appendCustomFiltersToMetricsQuery appends every custom filter as `key=value` to the metric selector.
+// Filters on a key the selector already compares are skipped, and so are expressions like `#A / #B`
+// (they are scoped by the rows they refer to).
+func appendCustomFiltersToMetricsQuery(query string, filters []*keptnv2.SLIFilter) (string, error) {
+	q, err := metricsquery.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	if q.Selector.IsRowExpression() {
+		return query, nil
+	}
+
+	for _, filter := range validCustomFilters(filters) {
+		if q.Selector.HasKey(filter.Key) {
+			continue
+		}
+		q.AddFilter(filter.Key, filter.Value)
+	}
+	return q.String(), nil
+}
+
+// appendCustomFiltersToLogQuery appends the value of every custom filter as a quoted keyword, e.g., `"eu-west-1"`,
+// to the scope of the log search (the part before the first `|`). A keyword matches the raw log lines while
+// `key="value"` would only match the indexed fields.
+func appendCustomFiltersToLogQuery(query string, filters []*keptnv2.SLIFilter) string {
+	filterExpressions := []string{}
+	for _, filter := range validCustomFilters(filters) {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(filter.Value)
+		filterExpressions = append(filterExpressions, `"`+value+`"`)
+	}
+	if len(filterExpressions) == 0 {
+		return query
+	}
+
+	scopeEnd := len(query)
+	inQuotes := false
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && inQuotes:
+			i++
+		case query[i] == '"':
+			inQuotes = !inQuotes
+		case query[i] == '|' && !inQuotes:
+			scopeEnd = i
+		}
+		if scopeEnd != len(query) {
+			break
+		}
+	}
+
+	scope := strings.TrimSpace(query[:scopeEnd])
+	if scope != "" {
+		scope += " "
+	}
+	scope += strings.Join(filterExpressions, " ")
+
+	if scopeEnd == len(query) {
+		return scope
+	}
+	return scope + " " + query[scopeEnd:]
+}
+
+// validCustomFilters skips the filters without a key
+func validCustomFilters(filters []*keptnv2.SLIFilter) []*keptnv2.SLIFilter {
+	valid := []*keptnv2.SLIFilter{}
+	for _, filter := range filters {
+		if filter != nil && strings.TrimSpace(filter.Key) != "" {
+			valid = append(valid, filter)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+var testCustomFilters = []*keptnv2.SLIFilter{
+	{Key: "region", Value: "eu-west-1"},
+	{Key: "region_name", Value: "Ireland"},
+	{Key: "pod", Value: "carts 1"},
+}
+
+func TestAppendCustomFiltersToMetricsQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"metric=cpu | sum", `metric=cpu region=eu-west-1 region_name=Ireland pod="carts 1" | sum`},
+		{"metric=cpu pod=carts-2 | quantize to 1m | sum by pod", `metric=cpu pod=carts-2 region=eu-west-1 region_name=Ireland | quantize to 1m | sum by pod`},
+		{"#A / #B * 100", "#A / #B * 100"},
+		{"(#A + #B) / 2", "(#A + #B) / 2"},
+		{"100 * #A / #B", "100 * #A / #B"},
+		{"100*#A/#B", "100*#A/#B"},
+		{"metric=cpu_user OR metric=cpu_system | sum", `(metric=cpu_user OR metric=cpu_system) region=eu-west-1 region_name=Ireland pod="carts 1" | sum`},
+		{"(metric=a or metric=b) namespace=prod | sum", `(metric=a or metric=b) namespace=prod region=eu-west-1 region_name=Ireland pod="carts 1" | sum`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := appendCustomFiltersToMetricsQuery(tt.query, testCustomFilters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAppendCustomFiltersToLogQuery(t *testing.T) {
+	filters := []*keptnv2.SLIFilter{{Key: "pod", Value: `carts "1"`}}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"_sourceCategory=prod/carts error | count", `_sourceCategory=prod/carts error "carts \"1\"" | count`},
+		{`_sourceCategory=prod/carts "a|b" | count`, `_sourceCategory=prod/carts "a|b" "carts \"1\"" | count`},
+		{"_sourceCategory=prod/carts", `_sourceCategory=prod/carts "carts \"1\""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := appendCustomFiltersToLogQuery(tt.query, filters)
+			if got != tt.want {
+				t.Errorf("Expected %s, but got %s", tt.want, got)
+			}
+		})
+	}
+}
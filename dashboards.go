@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// dashboardsPageSize is the max number of dashboards the Dashboards API returns in one request
+	dashboardsPageSize = 100
+
+	dashboardPanelType  = "SumoSearchPanel"
+	dashboardLayoutType = "Grid"
+)
+
+// dashboardsClient creates and updates dashboards using the Sumo Logic Dashboards API.
+// Check https://api.sumologic.com/docs/#tag/dashboardManagement for more info
+type dashboardsClient struct {
+	sumoAPIClient
+}
+
+// dashboard is a dashboard (new) with a panel per indicator
+type dashboard struct {
+	Id               string              `json:"id,omitempty"`
+	Title            string              `json:"title"`
+	Description      string              `json:"description"`
+	TopologyLabelMap dashboardTopology   `json:"topologyLabelMap"`
+	RefreshInterval  int                 `json:"refreshInterval"`
+	TimeRange        dashboardTimeRange  `json:"timeRange"`
+	Panels           []dashboardPanel    `json:"panels"`
+	Layout           dashboardLayout     `json:"layout"`
+	Variables        []dashboardVariable `json:"variables"`
+	Theme            string              `json:"theme"`
+}
+
+type dashboardTopology struct {
+	Data map[string][]string `json:"data"`
+}
+
+type dashboardTimeRange struct {
+	Type string                     `json:"type"`
+	From dashboardTimeRangeBoundary `json:"from"`
+}
+
+type dashboardTimeRangeBoundary struct {
+	Type         string `json:"type"`
+	RelativeTime string `json:"relativeTime"`
+}
+
+type dashboardPanel struct {
+	Key       string           `json:"key"`
+	Title     string           `json:"title"`
+	PanelType string           `json:"panelType"`
+	Queries   []dashboardQuery `json:"queries"`
+	// VisualSettings is the JSON of the chart settings (with the thresholds) as a string
+	VisualSettings string `json:"visualSettings"`
+
+	KeepVisualSettingsConsistentWithParent bool `json:"keepVisualSettingsConsistentWithParent"`
+}
+
+type dashboardQuery struct {
+	QueryKey         string `json:"queryKey"`
+	QueryString      string `json:"queryString"`
+	QueryType        string `json:"queryType"`
+	MetricsQueryMode string `json:"metricsQueryMode,omitempty"`
+}
+
+type dashboardLayout struct {
+	LayoutType       string                     `json:"layoutType"`
+	LayoutStructures []dashboardLayoutStructure `json:"layoutStructures"`
+}
+
+type dashboardLayoutStructure struct {
+	Key string `json:"key"`
+	// Structure is the JSON of the position and size of the panel as a string
+	Structure string `json:"structure"`
+}
+
+type dashboardVariable struct {
+	Name             string                  `json:"name"`
+	DisplayName      string                  `json:"displayName"`
+	DefaultValue     string                  `json:"defaultValue"`
+	SourceDefinition dashboardVariableSource `json:"sourceDefinition"`
+	AllowMultiSelect bool                    `json:"allowMultiSelect"`
+	IncludeAllOption bool                    `json:"includeAllOption"`
+	HideFromUI       bool                    `json:"hideFromUI"`
+}
+
+type dashboardVariableSource struct {
+	VariableSourceType string `json:"variableSourceType"`
+	// Values are the comma-separated values of a CsvVariableSourceDefinition
+	Values string `json:"values"`
+}
+
+type dashboardList struct {
+	Dashboards []dashboard `json:"dashboards"`
+	Next       string      `json:"next"`
+}
+
+func newDashboardsClient(basePath, accessId, accessKey string) *dashboardsClient {
+	return &dashboardsClient{
+		sumoAPIClient{
+			basePath:   basePath,
+			accessId:   accessId,
+			accessKey:  accessKey,
+			httpClient: &http.Client{Transport: newLimitedTransport(http.DefaultTransport, sumoAPILimiter)},
+		},
+	}
+}
+
+// findDashboard returns the id of the dashboard with the title (empty if there is none)
+func (c *dashboardsClient) findDashboard(ctx context.Context, title string) (string, error) {
+	token := ""
+	for {
+		page := dashboardList{}
+		path := fmt.Sprintf("/v2/dashboards?limit=%d", dashboardsPageSize)
+		if token != "" {
+			path += "&token=" + url.QueryEscape(token)
+		}
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return "", err
+		}
+		for _, d := range page.Dashboards {
+			if d.Title == title {
+				return d.Id, nil
+			}
+		}
+		if page.Next == "" {
+			return "", nil
+		}
+		token = page.Next
+	}
+}
+
+// upsertDashboard updates the dashboard with the same title or creates it and returns its id
+func (c *dashboardsClient) upsertDashboard(ctx context.Context, d dashboard) (string, error) {
+	id, err := c.findDashboard(ctx, d.Title)
+	if err != nil {
+		return "", err
+	}
+
+	saved := dashboard{}
+	if id != "" {
+		err := c.do(ctx, http.MethodPut, "/v2/dashboards/"+url.PathEscape(id), d, &saved)
+		return id, err
+	}
+
+	err = c.do(ctx, http.MethodPost, "/v2/dashboards", d, &saved)
+	return saved.Id, err
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIstioScope selects the Istio metrics of the service deployed by Keptn
+const defaultIstioScope = "destination_workload_namespace=$PROJECT-$STAGE (destination_workload=$SERVICE or destination_workload=$SERVICE-primary)"
+
+// defaultResponseTimeBuckets is the rate of the requests per bucket of the Istio response time histogram
+const defaultResponseTimeBuckets = "metric=istio_request_duration_milliseconds_bucket " + defaultIstioScope + " | quantize to 1m using max | rate | sum by le"
+
+// defaultSLIConfig are the indicators used when sumologic/sli.yaml doesn't define them (or doesn't exist at all).
+// They are written against the metrics of the Sumo Logic Kubernetes Collection and Istio
+// for services deployed by Keptn (namespace `<project>-<stage>`, workload `<service>` or `<service>-primary`).
+// The response time percentiles are computed from the buckets of the Istio histogram (check histogramPercentile).
+const defaultSLIConfig = `
+indicators:
+  throughput:
+    query: "metric=istio_requests_total ` + defaultIstioScope + ` | quantize to 1m using max | rate | sum"
+    reducer: avg
+    noDataValue: 0
+  error_rate:
+    rows:
+      A: "metric=istio_requests_total ` + defaultIstioScope + ` response_code=5* | quantize to 1m using max | rate | sum"
+      B: "metric=istio_requests_total ` + defaultIstioScope + ` | quantize to 1m using max | rate | sum"
+      C: "#A / #B * 100"
+    value: C
+    reducer: avg
+    noDataValue: 0
+  response_time_avg:
+    rows:
+      A: "metric=istio_request_duration_milliseconds_sum ` + defaultIstioScope + ` | quantize to 1m using max | rate | sum"
+      B: "metric=istio_request_duration_milliseconds_count ` + defaultIstioScope + ` | quantize to 1m using max | rate | sum"
+      C: "#A / #B"
+    value: C
+    reducer: avg
+  response_time_p50:
+    query: "` + defaultResponseTimeBuckets + `"
+    reducer: histogram_p50
+  response_time_p90:
+    query: "` + defaultResponseTimeBuckets + `"
+    reducer: histogram_p90
+  response_time_p95:
+    query: "` + defaultResponseTimeBuckets + `"
+    reducer: histogram_p95
+  cpu_usage:
+    query: "metric=container_cpu_usage_seconds_total namespace=$PROJECT-$STAGE container=$SERVICE | quantize to 1m using max | rate | sum"
+    reducer: avg
+  memory_usage:
+    query: "metric=container_memory_working_set_bytes namespace=$PROJECT-$STAGE container=$SERVICE | quantize to 1m using avg | sum"
+    reducer: avg
+`
+
+// defaultIndicators are the indicators of defaultSLIConfig
+var defaultIndicators = mustParseIndicators(defaultSLIConfig)
+
+func mustParseIndicators(content string) map[string]indicatorConfig {
+	config := struct {
+		Indicators map[string]indicatorConfig `yaml:"indicators"`
+	}{}
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		panic(fmt.Sprintf("invalid default SLI configuration: %v", err))
+	}
+	return config.Indicators
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+func TestDefaultIndicators(t *testing.T) {
+	data := &keptnv2.GetSLITriggeredEventData{
+		EventData: keptnv2.EventData{Project: "sockshop", Stage: "staging", Service: "carts"},
+	}
+	end := time.Now()
+	start := end.Add(-5 * time.Minute)
+
+	for _, name := range []string{"throughput", "error_rate", "response_time_avg", "response_time_p50", "response_time_p90", "response_time_p95", "cpu_usage", "memory_usage"} {
+		indicator, ok := defaultIndicators[name]
+		if !ok {
+			t.Errorf("Expected a default for %s", name)
+			continue
+		}
+
+		rows, _ := indicator.metricsRows()
+		for id, query := range rows {
+			expanded, err := replaceQueryParameters(data, query, start, end)
+			if err != nil {
+				t.Errorf("%s row %s: %v", name, id, err)
+				continue
+			}
+			if _, err := processQuery(expanded); err != nil {
+				t.Errorf("%s row %s: %v", name, id, err)
+			}
+		}
+	}
+}
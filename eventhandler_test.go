@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/keptn/go-utils/pkg/lib/v0_2_0/fake"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	keptn "github.com/keptn/go-utils/pkg/lib/keptn"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
@@ -40,8 +47,15 @@ func initializeTestObjects(eventFileName string) (*keptnv2.Keptn, *cloudevents.E
 }
 
 // Tests HandleActionTriggeredEvent
-// TODO: Add your test-code
 func TestHandleActionTriggeredEvent(t *testing.T) {
+	var resolved string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resolved = r.URL.Path
+	}))
+	defer server.Close()
+	env.SumoEndPt = server.URL
+	defer func() { env.SumoEndPt = "" }()
+
 	myKeptn, incomingEvent, err := initializeTestObjects("test-events/action.triggered.json")
 	if err != nil {
 		t.Error(err)
@@ -75,6 +89,17 @@ func TestHandleActionTriggeredEvent(t *testing.T) {
 	if keptnv2.GetFinishedEventType(keptnv2.ActionTaskName) != myKeptn.EventSender.(*fake.EventSender).SentEvents[1].Type() {
 		t.Errorf("Expected a action.finished event type")
 	}
+
+	finished := &actionFinishedEventData{}
+	if err := myKeptn.EventSender.(*fake.EventSender).SentEvents[1].DataAs(finished); err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != keptnv2.StatusSucceeded || resolved != "/v1/alerts/0000000000ABCDEF/resolve" {
+		t.Errorf("Expected the alert to be resolved, but got %+v (request %s)", finished, resolved)
+	}
+	if finished.Action.Action != specificEvent.Action.Action || finished.Action.Name != specificEvent.Action.Name {
+		t.Errorf("Expected the triggered action %+v in the finished event, but got %+v", specificEvent.Action, finished.Action)
+	}
 }
 
 // Tests HandleDeploymentTriggeredEvent
@@ -134,7 +159,7 @@ func TestHandleGetSliTriggered(t *testing.T) {
 		t.Errorf("Error getting keptn event data")
 	}
 
-	err = HandleGetSliTriggeredEvent(myKeptn, *incomingEvent, specificEvent)
+	err = HandleGetSliTriggeredEvent(context.Background(), myKeptn, *incomingEvent, specificEvent)
 	if err != nil {
 		t.Errorf("Error: " + err.Error())
 	}
@@ -157,6 +182,44 @@ func TestHandleGetSliTriggered(t *testing.T) {
 	}
 }
 
+func TestHandleGetSliTriggeredInvalidTimestamp(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/get-sli.triggered.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specificEvent := &keptnv2.GetSLITriggeredEventData{}
+	if err := incomingEvent.DataAs(specificEvent); err != nil {
+		t.Fatal(err)
+	}
+	specificEvent.GetSLI.Start = "yesterday"
+
+	if err := HandleGetSliTriggeredEvent(context.Background(), myKeptn, *incomingEvent, specificEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	sentEvents := myKeptn.EventSender.(*fake.EventSender).SentEvents
+	if len(sentEvents) != 2 || sentEvents[1].Type() != keptnv2.GetFinishedEventType(keptnv2.GetSLITaskName) {
+		t.Fatalf("Expected a get-sli.started and a get-sli.finished event, but got %v", sentEvents)
+	}
+
+	finished := &keptnv2.GetSLIFinishedEventData{}
+	if err := sentEvents[1].DataAs(finished); err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != keptnv2.StatusErrored || finished.Result != keptnv2.ResultFailed {
+		t.Errorf("Expected errored/failed, but got %s/%s", finished.Status, finished.Result)
+	}
+	if len(finished.GetSLI.IndicatorValues) != len(specificEvent.GetSLI.Indicators) {
+		t.Fatalf("Expected a failed result for every indicator, but got %d", len(finished.GetSLI.IndicatorValues))
+	}
+	for _, sliResult := range finished.GetSLI.IndicatorValues {
+		if sliResult.Success || !strings.Contains(sliResult.Message, "start timestamp") {
+			t.Errorf("Expected %s to fail because of the start timestamp, but got %+v", sliResult.Metric, sliResult)
+		}
+	}
+}
+
 // Tests the HandleReleaseTriggeredEvent Handler
 // TODO: Add your test-code
 func TestHandleReleaseTriggeredEvent(t *testing.T) {
@@ -177,3 +240,95 @@ func TestHandleReleaseTriggeredEvent(t *testing.T) {
 		t.Errorf("Error: " + err.Error())
 	}
 }
+
+// Tests that the indicators not fetched before the time budget ran out are reported as unfinished
+func TestFetchIndicatorsConcurrentlyTimeBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	indicators := []string{"throughput", "error_rate", "response_time_p95"}
+
+	results := fetchIndicatorsConcurrently(ctx, indicators, 1, func(indicatorName string) indicatorResult {
+		if indicatorName == "error_rate" {
+			// the budget runs out while the indicator is fetched
+			cancel()
+			return indicatorResult{err: fmt.Errorf("metrics query failed: %w", ctx.Err())}
+		}
+		return indicatorResult{value: 1}
+	})
+
+	unfinished := unfinishedIndicators(indicators, results)
+	if strings.Join(unfinished, ",") != "error_rate,response_time_p95" {
+		t.Errorf("Expected error_rate and response_time_p95 to be unfinished, but got %v", unfinished)
+	}
+	if results[0].err != nil || results[0].value != 1 {
+		t.Errorf("Expected throughput to be fetched, but got %+v", results[0])
+	}
+}
+
+// Tests that fetchIndicatorsConcurrently keeps the order of the indicators
+// and never runs more fetches in parallel than allowed
+func TestFetchIndicatorsConcurrently(t *testing.T) {
+	indicators := []string{"1", "2", "3", "4", "5", "6", "7"}
+	concurrency := 3
+
+	var running, maxRunning int32
+	results := fetchIndicatorsConcurrently(context.Background(), indicators, concurrency, func(indicatorName string) indicatorResult {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		value, _ := strconv.ParseFloat(indicatorName, 64)
+		return indicatorResult{value: value}
+	})
+
+	if len(results) != len(indicators) {
+		t.Fatalf("Expected %d results, but got %d", len(indicators), len(results))
+	}
+	for i, res := range results {
+		if res.value != float64(i+1) {
+			t.Errorf("Expected result %d to be for indicator %s, but got %v", i, indicators[i], res.value)
+		}
+	}
+	if maxRunning > int32(concurrency) {
+		t.Errorf("Expected at most %d parallel fetches, but got %d", concurrency, maxRunning)
+	}
+}
+
+// Tests that processQuery turns the quantize operator into the API parameters
+func TestProcessQuery(t *testing.T) {
+	processed, err := processQuery("metric=container_cpu_usage_seconds_total service=carts | quantize to 300s using avg | sum by pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.Query != "metric=container_cpu_usage_seconds_total service=carts | sum by pod" {
+		t.Errorf("Unexpected query: %s", processed.Query)
+	}
+	if processed.Quantization != 300000 || processed.Rollup != "Avg" {
+		t.Errorf("Expected quantization 300000 using Avg, but got %d using %s", processed.Quantization, processed.Rollup)
+	}
+
+	processed, err = processQuery("metric=cpu service=carts | timeshift 1d | quantize to 1m using max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.Query != "metric=cpu service=carts" || processed.Timeshift != 24*time.Hour || processed.Rollup != "Max" {
+		t.Errorf("Expected the timeshift to be taken out of the query, but got %+v", processed)
+	}
+
+	for _, query := range []string{
+		"metric=cpu | timeshift 1d | timeshift 1w",
+		"metric=cpu | timeshift yesterday",
+		"metric=cpu | quantize to 1m | quantize to 5m",
+		"metric=cpu | sum | quantize to 1m",
+		"metric=cpu | quantize to 1m using median",
+	} {
+		if _, err := processQuery(query); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
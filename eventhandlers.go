@@ -1,14 +1,13 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"math"
 	"net/http"
-	"os"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -16,29 +15,17 @@ import (
 	"golang.org/x/text/language"
 
 	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip"
-	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
+	"github.com/keptn-sandbox/sumologic-service/pkg/metricsquery"
 	keptn "github.com/keptn/go-utils/pkg/lib"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
 )
 
 const (
-	sliFile                        = "sumologic/sli.yaml"
-	defaultSleepBeforeAPIInSeconds = 60
+	sliFile               = "sumologic/sli.yaml"
+	defaultSLIConcurrency = 4
 )
 
-// We have to put a min of 60s of sleep for the Sumo Logic API to reflect the data correctly
-var sleepBeforeAPIInSeconds int
-
-func init() {
-	var err error
-	sleepBeforeAPIInSeconds, err = strconv.Atoi(strings.TrimSpace(os.Getenv("SLEEP_BEFORE_API_IN_SECONDS")))
-	if err != nil || sleepBeforeAPIInSeconds < defaultSleepBeforeAPIInSeconds {
-		log.Infof("defaulting SLEEP_BEFORE_API_IN_SECONDS to 30s because it was set to '%v' which is less than the min allowed value of 30s", sleepBeforeAPIInSeconds)
-		sleepBeforeAPIInSeconds = defaultSleepBeforeAPIInSeconds
-	}
-}
-
 /**
 * Here are all the handler functions for the individual event
 * See https://github.com/keptn/spec/blob/0.8.0-alpha/cloudevents.md for details on the payload
@@ -60,11 +47,65 @@ func OldHandleConfigureMonitoringEvent(myKeptn *keptnv2.Keptn, incomingEvent clo
 	return nil
 }
 
-// HandleConfigureMonitoringTriggeredEvent handles configure-monitoring.triggered events
-// TODO: add in your handler code
+// HandleConfigureMonitoringTriggeredEvent handles configure-monitoring.triggered events if the type is sumologic
+// by creating or updating a Sumo Logic monitor for every objective in the slo.yaml of the service
+// and a dashboard with a panel per indicator
 func HandleConfigureMonitoringTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.ConfigureMonitoringTriggeredEventData) error {
 	log.Printf("Handling configure-monitoring.triggered Event: %s", incomingEvent.Context.GetID())
 
+	if data.ConfigureMonitoring.Type != monitoringType {
+		log.Printf("Not handling configure-monitoring event as it is meant for %s", data.ConfigureMonitoring.Type)
+		return nil
+	}
+
+	_, err := myKeptn.SendTaskStartedEvent(data, ServiceName)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to send task started CloudEvent (%s), aborting...", err.Error())
+		log.Println(errMsg)
+		return err
+	}
+
+	window := env.MonitorEvaluationWindow
+	if window < time.Minute {
+		window = defaultMonitorEvaluationWindow
+	}
+
+	monitors := newMonitorsClient(env.SumoEndPt, env.AccessId, env.AccessKey)
+	dashboards := newDashboardsClient(env.SumoEndPt, env.AccessId, env.AccessKey)
+	result, skipped, err := configureMonitors(myKeptn, data, monitors, dashboards, window)
+
+	finishedEventData := &configureMonitoringFinishedEventData{
+		EventData: keptnv2.EventData{
+			Status: keptnv2.StatusSucceeded,
+			Result: keptnv2.ResultPass,
+			Labels: data.Labels,
+		},
+		SumoLogic: result,
+	}
+
+	messages := []string{fmt.Sprintf("created or updated %d monitors", len(result.MonitorIds))}
+	if result.DashboardId != "" {
+		messages = append(messages, fmt.Sprintf("created or updated the dashboard %s", dashboardTitle(data.Project, data.Service)))
+	}
+	if len(skipped) > 0 {
+		finishedEventData.Result = keptnv2.ResultWarning
+		messages = append(messages, fmt.Sprintf("skipped objectives: %s", strings.Join(skipped, "; ")))
+	}
+	if err != nil {
+		log.Errorf("failed to configure monitoring: %v", err)
+		finishedEventData.Status = keptnv2.StatusErrored
+		finishedEventData.Result = keptnv2.ResultFailed
+		messages = append(messages, err.Error())
+	}
+	finishedEventData.Message = strings.Join(messages, "; ")
+
+	_, err = myKeptn.SendTaskFinishedEvent(finishedEventData, ServiceName)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to send task finished CloudEvent (%s), aborting...", err.Error())
+		log.Println(errMsg)
+		return err
+	}
+
 	return nil
 }
 
@@ -108,10 +149,79 @@ func HandleReleaseTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudeven
 	return nil
 }
 
+// HandleTaskFinishedEvent handles deployment, test, evaluation and release .finished events
+// by sending them as JSON logs to the Sumo Logic HTTP Source (if one is configured)
+func HandleTaskFinishedEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.EventData) error {
+	log.Printf("Handling %s Event: %s", incomingEvent.Type(), incomingEvent.Context.GetID())
+
+	if env.HTTPSourceURL == "" {
+		log.Debugf("Not sending %s to Sumo Logic as HTTP_SOURCE_URL is not set", incomingEvent.Type())
+		return nil
+	}
+
+	client := newHTTPSourceClient(env.HTTPSourceURL)
+	err := sendLifecycleEvent(client, env.LifecycleSourceCategory, incomingEvent, data)
+	if err != nil {
+		log.Errorf("failed to send %s to the Sumo Logic HTTP Source: %v", incomingEvent.Type(), err)
+		return err
+	}
+
+	return nil
+}
+
+// HandleEvaluationFinishedEvent handles evaluation.finished events by sending them as JSON logs to the
+// Sumo Logic HTTP Source and the score and the indicators as metrics to the HTTP metrics source (if they are configured)
+func HandleEvaluationFinishedEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.EvaluationFinishedEventData) error {
+	logsErr := HandleTaskFinishedEvent(myKeptn, incomingEvent, &data.EventData)
+
+	if env.MetricsSourceURL == "" {
+		log.Debugf("Not sending the quality gate metrics to Sumo Logic as METRICS_SOURCE_URL is not set")
+		return logsErr
+	}
+
+	client := newHTTPSourceClient(env.MetricsSourceURL)
+	err := sendQualityGateMetrics(client, env.MetricsFormat, incomingEvent, data)
+	if err != nil {
+		log.Errorf("failed to send the quality gate metrics to the Sumo Logic HTTP Source: %v", err)
+		return err
+	}
+
+	return logsErr
+}
+
+// sendGetSLIErroredEvent sends a get-sli.finished event with status=error and result=failed
+// and reports every indicator as failed with the reason
+func sendGetSLIErroredEvent(myKeptn *keptnv2.Keptn, data *keptnv2.GetSLITriggeredEventData, labels map[string]string, errMsg string) error {
+	sliResults := []*keptnv2.SLIResult{}
+	for _, indicatorName := range data.GetSLI.Indicators {
+		sliResults = append(sliResults, &keptnv2.SLIResult{
+			Metric:  indicatorName,
+			Success: false,
+			Message: errMsg,
+		})
+	}
+
+	_, err := myKeptn.SendTaskFinishedEvent(&keptnv2.GetSLIFinishedEventData{
+		EventData: keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Labels:  labels,
+			Message: errMsg,
+		},
+		GetSLI: keptnv2.GetSLIFinished{
+			Start:           data.GetSLI.Start,
+			End:             data.GetSLI.End,
+			IndicatorValues: sliResults,
+		},
+	}, ServiceName)
+
+	return err
+}
+
 // HandleGetSliTriggeredEvent handles get-sli.triggered events if SLIProvider == sumologic-service
 // This function acts as an example showing how to handle get-sli events by sending .started and .finished events
 // TODO: adapt handler code to your needs
-func HandleGetSliTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.GetSLITriggeredEventData) error {
+func HandleGetSliTriggeredEvent(ctx context.Context, myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.GetSLITriggeredEventData) error {
 	log.Printf("Handling get-sli.triggered Event: %s", incomingEvent.Context.GetID())
 
 	// Step 1 - Do we need to do something?
@@ -131,30 +241,32 @@ func HandleGetSliTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevent
 		return err
 	}
 
+	// Step 4 - prep-work
+	// Get any additional input / configuration data
+	// - Labels: get the incoming labels for potential config data and use it to pass more labels on result, e.g: links
+	// - SLI.yaml: if your service uses SLI.yaml to store query definitions for SLIs get that file from Keptn
+	labels := make(map[string]string, len(data.Labels))
+	for name, value := range data.Labels {
+		labels[name] = value
+	}
+
 	start, err := parseUnixTimestamp(data.GetSLI.Start)
 	if err != nil {
-		log.Errorf("unable to parse sli start timestamp: %v", err)
-		return err
+		errMsg := fmt.Sprintf("unable to parse sli start timestamp: %v", err)
+		log.Error(errMsg)
+		return sendGetSLIErroredEvent(myKeptn, data, labels, errMsg)
 	}
 	end, err := parseUnixTimestamp(data.GetSLI.End)
 	if err != nil {
-		log.Errorf("unable to parse sli end timestamp: %v", err)
-		return err
-	}
-
-	// Step 4 - prep-work
-	// Get any additional input / configuration data
-	// - Labels: get the incoming labels for potential config data and use it to pass more labels on result, e.g: links
-	// - SLI.yaml: if your service uses SLI.yaml to store query definitions for SLIs get that file from Keptn
-	labels := data.Labels
-	if labels == nil {
-		labels = make(map[string]string)
+		errMsg := fmt.Sprintf("unable to parse sli end timestamp: %v", err)
+		log.Error(errMsg)
+		return sendGetSLIErroredEvent(myKeptn, data, labels, errMsg)
 	}
 
 	// Step 5 - get SLI Config File
 	// Get SLI File from sumologic-service subdirectory of the config repo - to add the file use:
 	//   keptn add-resource --project=PROJECT --stage=STAGE --service=SERVICE --resource=my-sli-config.yaml  --resourceUri=sumologic-service/sli.yaml
-	sliConfig, err := myKeptn.GetSLIConfiguration(data.Project, data.Stage, data.Service, sliFile)
+	sliConfig, err := getSLIConfiguration(myKeptn, data.Project, data.Stage, data.Service, sliFile)
 	log.Debugf("SLI config: %v", sliConfig)
 
 	// FYI you do not need to "fail" if sli.yaml is missing, you can also assume smart defaults like we do
@@ -164,21 +276,21 @@ func HandleGetSliTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevent
 		errMsg := fmt.Sprintf("Failed to fetch SLI file %s from config repo: %s", sliFile, err.Error())
 		log.Error(errMsg)
 		// send a get-sli.finished event with status=error and result=failed back to Keptn
-
-		_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
-			Status: keptnv2.StatusErrored,
-			Result: keptnv2.ResultFailed,
-			Labels: labels,
-		}, ServiceName)
-
-		return err
+		return sendGetSLIErroredEvent(myKeptn, data, labels, errMsg)
 	}
 
 	// Step 6 - do your work - iterate through the list of requested indicators and return their values
 	// Indicators: this is the list of indicators as requested in the SLO.yaml
 	// SLIResult: this is the array that will receive the results
 	indicators := data.GetSLI.Indicators
-	sliResults := []*keptnv2.SLIResult{}
+
+	// the time budget covers all the queries, retries and polls of the event
+	timeout := env.GetSLITimeout
+	if timeout <= 0 {
+		timeout = defaultGetSLITimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
 	client := cip.APIClient{
 		Cfg: &cip.Configuration{
@@ -186,8 +298,18 @@ func HandleGetSliTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevent
 				AccessId:  env.AccessId,
 				AccessKey: env.AccessKey,
 			},
-			BasePath:   env.SumoEndPt,
-			HTTPClient: &http.Client{},
+			BasePath: env.SumoEndPt,
+			HTTPClient: &http.Client{
+				Transport: &contextTransport{
+					ctx: ctx,
+					next: newRetryTransport(newLimitedTransport(http.DefaultTransport, sumoAPILimiter), retryPolicy{
+						MaxRetries: env.APIMaxRetries,
+						BaseDelay:  env.APIRetryBaseDelay,
+						MaxDelay:   defaultRetryMaxDelay,
+						Budget:     env.APIRetryBudget,
+					}),
+				},
+			},
 		},
 	}
 
@@ -202,73 +324,73 @@ func HandleGetSliTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevent
 			End:   data.GetSLI.End,
 		},
 	}
-	var sliResult *keptnv2.SLIResult
+	// Pulling the data from Sumo Logic api immediately gives incomplete data in the api response
+	// so we poll the api until the data is complete or until the deadline
+	// (the deadline is shared by all the indicators because they are fetched in parallel)
+	dataTimeout := env.DataReadinessTimeout
+	if dataTimeout <= 0 {
+		dataTimeout = defaultDataReadinessTimeout
+	}
+	pollInterval := env.DataPollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultDataPollInterval
+	}
 
-	for _, indicatorName := range indicators {
-		// Pulling the data from Sumo Logic api immediately gives incorrect data in the api response
-		// we have to wait for some time for the correct data to be reflected in the api response
-		log.Debugf("waiting for %vs so that the metrics data is reflected correctly in the api", sleepBeforeAPIInSeconds)
-		time.Sleep(time.Second * time.Duration(sleepBeforeAPIInSeconds))
-		query := replaceQueryParameters(data, sliConfig[indicatorName], start, end)
-		log.Debugf("query: %v, from: %v, to: %v", query, start.Unix(), end.Unix())
+	searchTimeout := env.SearchJobTimeout
+	if searchTimeout <= 0 {
+		searchTimeout = defaultSearchJobTimeout
+	}
 
-		formattedQuery, quantizeDuration, quantizeRollup, err := processQuery(query)
-		if err != nil {
-			log.Error(err)
-			getSliFinishedEventData.Status = keptnv2.StatusErrored
-			getSliFinishedEventData.Result = keptnv2.ResultFailed
-			_, sendErr := myKeptn.SendTaskFinishedEvent(getSliFinishedEventData, ServiceName)
-			if sendErr != nil {
-				log.Error(sendErr)
-			}
-			return err
-		}
+	fetcher := &sliFetcher{
+		ctx:           ctx,
+		data:          data,
+		start:         start,
+		end:           end,
+		metricsClient: &client,
+		searchClient:  newSearchJobClient(env.SumoEndPt, env.AccessId, env.AccessKey),
+		dataDeadline:  time.Now().Add(dataTimeout),
+		dataTimeout:   dataTimeout,
+		pollInterval:  pollInterval,
+		searchTimeout: searchTimeout,
+
+		appendCustomFilters: env.AppendCustomFilters,
+		uiURL:               sumoUIURL(env.SumoEndPt),
+	}
 
-		// It takes some time until the metrics
-		// start reflecting in the SumoLogic API results
-		time.Sleep(time.Second * 30)
-
-		req := types.MetricsQueryRequest{
-			Queries: []types.MetricsQueryRow{
-				types.MetricsQueryRow{
-					Query:        formattedQuery,
-					RowId:        "A",
-					Quantization: quantizeDuration,
-					Rollup:       quantizeRollup,
-				},
-			},
-			TimeRange: &types.ResolvableTimeRange{
-				Type_: "BeginBoundedTimeRange",
-				From: types.TimeRangeBoundary{
-					Type:        "EpochTimeRangeBoundary",
-					EpochMillis: start.UnixMilli(),
-					RangeName:   "from",
-				},
-				To: types.TimeRangeBoundary{
-					Type:        "EpochTimeRangeBoundary",
-					EpochMillis: end.UnixMilli(),
-					RangeName:   "to",
-				},
-			},
-		}
-		log.Debugf("metrics query request: %v", req)
-		log.Debugf("formattedQuery: %v", formattedQuery)
-		mRes, hRes, err := client.RunMetricsQueries(req)
-		log.Debugf("metrics query response: %v", mRes)
-		log.Debugf("metric value from sumologic: %v", mRes.QueryResult[0].TimeSeriesList.TimeSeries[0].Points.Values[0])
-		log.Debugf("http response: %v", *hRes)
-		if err != nil {
-			log.Error(err)
-			getSliFinishedEventData.EventData.Status = keptnv2.StatusErrored
-			getSliFinishedEventData.EventData.Result = keptnv2.ResultFailed
-		} else {
-			sliResult = &keptnv2.SLIResult{
-				Metric: indicatorName,
-				Value:  mRes.QueryResult[0].TimeSeriesList.TimeSeries[0].Points.Values[0],
+	results := fetchIndicatorsConcurrently(ctx, indicators, env.SLIConcurrency, func(indicatorName string) indicatorResult {
+		indicator, ok := sliConfig[indicatorName]
+		if !ok {
+			indicator, ok = defaultIndicators[indicatorName]
+			if !ok {
+				return indicatorResult{err: fmt.Errorf("%w: %s is not defined in %s on project, stage or service level and has no default", errIndicatorNotConfigured, indicatorName, sliFile)}
 			}
-			sliResults = append(sliResults, sliResult)
+			log.Infof("indicator %s is not defined in %s, using the default definition", indicatorName, sliFile)
 		}
+		return fetcher.fetch(indicatorName, indicator)
+	})
 
+	// the links to the queries are shown next to the SLIs in the Bridge
+	for name, link := range sliLinkLabels(indicators, results) {
+		labels[name] = link
+	}
+	getSliFinishedEventData.EventData.Labels = labels
+
+	sliResults, status, result, message := toSLIResults(indicators, results)
+	getSliFinishedEventData.EventData.Status = status
+	getSliFinishedEventData.EventData.Result = result
+	getSliFinishedEventData.EventData.Message = message
+	// the indicators fetched in time are still reported, but the event is errored
+	if ctx.Err() != nil {
+		getSliFinishedEventData.EventData.Status = keptnv2.StatusErrored
+		getSliFinishedEventData.EventData.Result = keptnv2.ResultFailed
+		getSliFinishedEventData.EventData.Message = fmt.Sprintf("the time budget of %v (GET_SLI_TIMEOUT) ran out, unfinished indicators: %s", timeout, strings.Join(unfinishedIndicators(indicators, results), ", "))
+		if message != "" {
+			getSliFinishedEventData.EventData.Message += "; " + message
+		}
+		message = getSliFinishedEventData.EventData.Message
+	}
+	if message != "" {
+		log.Warn(message)
 	}
 
 	getSliFinishedEventData.GetSLI.IndicatorValues = sliResults
@@ -296,40 +418,117 @@ func HandleProblemEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event,
 	return nil
 }
 
-// HandleActionTriggeredEvent handles action.triggered events
-// TODO: add in your handler code
+// HandleActionTriggeredEvent handles action.triggered events of the Sumo Logic remediation actions
+// (mute/unmute monitors or folders, disable/enable monitors, resolve alerts and log snapshots)
 func HandleActionTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.ActionTriggeredEventData) error {
 	log.Printf("Handling Action Triggered Event: %s", incomingEvent.Context.GetID())
 	log.Printf("Action=%s\n", data.Action.Action)
 
 	// check if action is supported
-	if data.Action.Action == "action-xyz" {
-		// -----------------------------------------------------
-		// 1. Send Action.Started Cloud-Event
-		// -----------------------------------------------------
-		myKeptn.SendTaskStartedEvent(data, ServiceName)
-
-		// -----------------------------------------------------
-		// 2. Implement your remediation action here
-		// -----------------------------------------------------
-		time.Sleep(5 * time.Second) // Example: Wait 5 seconds. Maybe the problem fixes itself.
-
-		// -----------------------------------------------------
-		// 3. Send Action.Finished Cloud-Event
-		// -----------------------------------------------------
-		myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
-			Status:  keptnv2.StatusSucceeded, // alternative: keptnv2.StatusErrored
-			Result:  keptnv2.ResultPass,      // alternative: keptnv2.ResultFailed
-			Message: "Successfully sleeped!",
-		}, ServiceName)
-
-	} else {
+	if !isSumoAction(data.Action.Action) {
 		log.Printf("Retrieved unknown action %s, skipping...", data.Action.Action)
 		return nil
 	}
+
+	_, err := myKeptn.SendTaskStartedEvent(data, ServiceName)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to send task started CloudEvent (%s), aborting...", err.Error())
+		log.Println(errMsg)
+		return err
+	}
+
+	timeout := actionTimeout
+	if data.Action.Action == actionLogSnapshot && env.SearchJobTimeout > timeout {
+		timeout = env.SearchJobTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	runner := &actionRunner{
+		monitors:         newMonitorsClient(env.SumoEndPt, env.AccessId, env.AccessKey),
+		search:           newSearchJobClient(env.SumoEndPt, env.AccessId, env.AccessKey),
+		uiURL:            sumoUIURL(env.SumoEndPt),
+		logSnapshotQuery: env.LogSnapshotQuery,
+		now:              time.Now,
+	}
+	if runner.logSnapshotQuery == "" {
+		runner.logSnapshotQuery = defaultLogSnapshotQuery
+	}
+	result, err := runner.run(ctx, data)
+
+	labels := map[string]string{}
+	for name, value := range data.Labels {
+		labels[name] = value
+	}
+	for name, value := range result.labels() {
+		labels[name] = value
+	}
+
+	finishedEventData := &actionFinishedEventData{
+		EventData: keptnv2.EventData{
+			Status:  keptnv2.StatusSucceeded,
+			Result:  keptnv2.ResultPass,
+			Labels:  labels,
+			Message: result.message(),
+		},
+		Action:    data.Action,
+		SumoLogic: result,
+	}
+	if err != nil {
+		log.Errorf("action %s failed: %v", data.Action.Action, err)
+		finishedEventData.Status = keptnv2.StatusErrored
+		finishedEventData.Result = keptnv2.ResultFailed
+		finishedEventData.Message = err.Error()
+	}
+
+	_, err = myKeptn.SendTaskFinishedEvent(finishedEventData, ServiceName)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to send task finished CloudEvent (%s), aborting...", err.Error())
+		log.Println(errMsg)
+		return err
+	}
+
 	return nil
 }
 
+// fetchIndicatorsConcurrently runs fetch for every indicator using a pool of
+// at most `concurrency` workers. The results are returned in the same order
+// as the indicators. The indicators which haven't started once ctx is done fail with the error of ctx.
+func fetchIndicatorsConcurrently(ctx context.Context, indicators []string, concurrency int, fetch func(indicatorName string) indicatorResult) []indicatorResult {
+	if concurrency <= 0 {
+		concurrency = defaultSLIConcurrency
+	}
+	if concurrency > len(indicators) {
+		concurrency = len(indicators)
+	}
+
+	results := make([]indicatorResult, len(indicators))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i] = indicatorResult{err: fmt.Errorf("not fetched: %w", err)}
+					continue
+				}
+				results[i] = fetch(indicators[i])
+			}
+		}()
+	}
+
+	for i := range indicators {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
 func parseUnixTimestamp(timestamp string) (time.Time, error) {
 	parsedTime, err := time.Parse(time.RFC3339, timestamp)
 	if err == nil {
@@ -344,16 +543,9 @@ func parseUnixTimestamp(timestamp string) (time.Time, error) {
 	return unix, nil
 }
 
-func replaceQueryParameters(data *keptnv2.GetSLITriggeredEventData, query string, start, end time.Time) string {
-	query = strings.Replace(query, "$PROJECT", data.Project, -1)
-	query = strings.Replace(query, "$STAGE", data.Stage, -1)
-	query = strings.Replace(query, "$SERVICE", data.Service, -1)
-	query = strings.Replace(query, "$project", data.Project, -1)
-	query = strings.Replace(query, "$stage", data.Stage, -1)
-	query = strings.Replace(query, "$service", data.Service, -1)
-	durationString := fmt.Sprintf("%ds", getDurationInSeconds(start, end))
-	query = strings.Replace(query, "$DURATION", durationString, -1)
-	return query
+// replaceQueryParameters replaces the placeholders in the query (check newPlaceholderValues for the supported placeholders)
+func replaceQueryParameters(data *keptnv2.GetSLITriggeredEventData, query string, start, end time.Time) (string, error) {
+	return expandPlaceholders(query, newPlaceholderValues(data, start, end))
 }
 
 func getDurationInSeconds(start, end time.Time) int64 {
@@ -362,46 +554,110 @@ func getDurationInSeconds(start, end time.Time) int64 {
 	return int64(math.Ceil(seconds))
 }
 
-// processQuery takes the query, parses it and returns the
-// 1. formattedQuery after removing `quantize` operator (because it is
-// 	not supported by the API <- syntactic sugar added by us)
-// 2. quantizeVal which is in milliseconds (int64)
-// 3. rollup which is either of Avg, Min, Max, Sum, or Count.
+// processedQuery is a metrics query from sli.yaml turned into what the Metrics Query API expects
+type processedQuery struct {
+	// Query is the query without the operators the API doesn't support in the query text
+	Query string
+	// Quantization is the quantize interval in milliseconds (0 if the query has no `quantize`)
+	Quantization int64
+	// Rollup is either of Avg, Min, Max, Sum, Count or None (empty if not specified)
+	Rollup string
+	// Timeshift is how far the time range of the query is shifted into the past (0 if the query has no `timeshift`)
+	Timeshift time.Duration
+	// ClientOperators are applied to the time series returned by the API (in order)
+	ClientOperators []clientOperator
+}
+
+// processQuery parses the query and returns the query for the Metrics Query API
+// after removing the `quantize` and `timeshift` operators (because they are not supported by the API
+// in the query text but only through the quantization and rollup parameters and the time range)
+// and the operators that are emulated on the returned time series (`fillmissing`)
 // Check https://help.sumologic.com/Metrics/Metric-Queries-and-Alerts/07Metrics_Operators/quantize#quantize-syntax
 // for more info
-func processQuery(query string) (string, int64, string, error) {
+func processQuery(query string) (processedQuery, error) {
+	q, err := metricsquery.Parse(query)
+	if err != nil {
+		return processedQuery{}, fmt.Errorf("%w in query `%s`", err, query)
+	}
+
+	processed := processedQuery{}
 
-	// Ensure there is only one `quantize` in the query
-	re := regexp.MustCompile(`quantize`)
-	matches := re.FindAllString(query, -1)
-	if len(matches) != 1 {
-		return "", 0, "", errors.New("please specify 1 `quantize` in the query")
+	// `timeshift` can be anywhere in the query because shifting the time range works the same for all the operators
+	timeshiftOps := q.RemoveOperators("timeshift")
+	if len(timeshiftOps) > 1 {
+		return processedQuery{}, fmt.Errorf("%w in query `%s`", &metricsquery.SyntaxError{
+			Pos: timeshiftOps[1].Pos,
+			Msg: "only 1 `timeshift` is allowed",
+		}, query)
+	}
+	if len(timeshiftOps) == 1 {
+		timeshift, err := metricsquery.ParseTimeshift(timeshiftOps[0])
+		if err != nil {
+			return processedQuery{}, fmt.Errorf("%w in query `%s`", err, query)
+		}
+		processed.Timeshift = timeshift
 	}
 
-	// Parse the quantize duration and Roll up type (e.g., avg, sum) from the query
-	qRe := regexp.MustCompile(`quantize\s+to\s+(\d+[a-z])\s+using\s+([a-z]+)\s*\|?`)
-	quantizePart := qRe.FindAllString(query, -1)[0]
-	if len(quantizePart) == 0 {
-		return "", 0, "", fmt.Errorf("`quantize` part of the query should match the regex `%s`", qRe.String())
+	quantizeOps := q.FindOperators("quantize")
+	if len(quantizeOps) > 1 {
+		return processedQuery{}, fmt.Errorf("%w in query `%s`", &metricsquery.SyntaxError{
+			Pos: quantizeOps[1].Pos,
+			Msg: "only 1 `quantize` is allowed",
+		}, query)
 	}
 
-	// Output of FindAllStringSubmatch is of the form [["actual", "result", "goes", "here"]]
-	submatches := qRe.FindAllStringSubmatch(quantizePart, -1)[0]
+	if len(quantizeOps) == 1 {
+		// the API applies quantization before all the operators in the query
+		if q.Operators[0].Name != "quantize" {
+			return processedQuery{}, fmt.Errorf("%w in query `%s`", &metricsquery.SyntaxError{
+				Pos: quantizeOps[0].Pos,
+				Msg: "`quantize` should come right after the metric selector",
+			}, query)
+		}
 
-	query = strings.ReplaceAll(query, quantizePart, " ")
+		quantize, err := metricsquery.ParseQuantize(quantizeOps[0])
+		if err != nil {
+			return processedQuery{}, fmt.Errorf("%w in query `%s`", err, query)
+		}
+		if quantize.DropLast {
+			return processedQuery{}, fmt.Errorf("%w in query `%s`", &metricsquery.SyntaxError{
+				Pos: quantizeOps[0].Pos,
+				Msg: "`drop last` is not supported by the Metrics Query API",
+			}, query)
+		}
 
-	quantizeVal, err := time.ParseDuration(submatches[1])
-	if err != nil {
-		return "", 0, "", errors.New("couldn't parse the value for quantize interval/duration")
+		q.RemoveOperators("quantize")
+		processed.Quantization = quantize.Interval.Milliseconds()
+		processed.Rollup = cases.Title(language.English).String(quantize.Rollup)
 	}
 
-	formattedQuery := strings.TrimSpace(query)
+	// the emulated operators are applied to the time series returned by the API
+	// so they have to come after all the operators the API runs
+	for i, op := range q.Operators {
+		if !clientOperatorNames[op.Name] {
+			continue
+		}
+		for _, after := range q.Operators[i+1:] {
+			if !clientOperatorNames[after.Name] {
+				return processedQuery{}, fmt.Errorf("%w in query `%s`", &metricsquery.SyntaxError{
+					Pos: after.Pos,
+					Msg: fmt.Sprintf("`%s` can't come after `%s` (`%s` is applied to the result of the query)", after.Name, op.Name, op.Name),
+				}, query)
+			}
+		}
 
-	if formattedQuery[len(formattedQuery)-1] == '|' {
-		formattedQuery = formattedQuery[:len(formattedQuery)-1]
+		for _, clientOp := range q.Operators[i:] {
+			emulated, err := newClientOperator(clientOp, processed)
+			if err != nil {
+				return processedQuery{}, fmt.Errorf("%w in query `%s`", err, query)
+			}
+			processed.ClientOperators = append(processed.ClientOperators, emulated)
+		}
+		q.Operators = q.Operators[:i]
+		break
 	}
 
-	c := cases.Title(language.English)
+	processed.Query = q.String()
 
-	return formattedQuery, quantizeVal.Milliseconds(), c.String(submatches[2]), nil
+	return processed, nil
 }
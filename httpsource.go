@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	httpSourceTimeout         = 30 * time.Second
+	httpSourceLogsContentType = "text/plain"
+)
+
+// httpSourceClient sends data to a Sumo Logic HTTP Source.
+// Check https://help.sumologic.com/03Send-Data/Sources/02Sources-for-Hosted-Collectors/HTTP-Source/Upload-Data-to-an-HTTP-Source
+// for more info
+type httpSourceClient struct {
+	url        string
+	httpClient *http.Client
+}
+
+// httpSourceMetadata overrides the metadata of the HTTP Source for the data sent in a request
+type httpSourceMetadata struct {
+	category string
+	name     string
+	host     string
+	// fields are added to every log line or data point (as X-Sumo-Fields for logs and X-Sumo-Dimensions for metrics)
+	fields map[string]string
+}
+
+func newHTTPSourceClient(url string) *httpSourceClient {
+	return &httpSourceClient{
+		url:        url,
+		httpClient: &http.Client{Timeout: httpSourceTimeout},
+	}
+}
+
+// send posts the body to the HTTP Source, the content type picks logs (text/plain) or the metrics format
+func (c *httpSourceClient) send(ctx context.Context, contentType string, metadata httpSourceMetadata, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if metadata.category != "" {
+		req.Header.Set("X-Sumo-Category", metadata.category)
+	}
+	if metadata.name != "" {
+		req.Header.Set("X-Sumo-Name", metadata.name)
+	}
+	if metadata.host != "" {
+		req.Header.Set("X-Sumo-Host", metadata.host)
+	}
+	if len(metadata.fields) > 0 {
+		fieldsHeader := "X-Sumo-Fields"
+		if contentType != httpSourceLogsContentType {
+			fieldsHeader = "X-Sumo-Dimensions"
+		}
+		req.Header.Set(fieldsHeader, formatHTTPSourceFields(metadata.fields))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		// the URL of the HTTP Source has the token of the source, that's why it isn't in the error
+		return fmt.Errorf("HTTP Source returned %s: %s", res.Status, string(resBody))
+	}
+	return nil
+}
+
+// formatHTTPSourceFields formats the fields as `key1=value1,key2=value2` (sorted by key, without empty values)
+func formatHTTPSourceFields(fields map[string]string) string {
+	keys := []string{}
+	for key, value := range fields {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		// commas and equal signs separate the fields
+		value := strings.NewReplacer(",", "_", "=", "_").Replace(fields[key])
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+const (
+	defaultLifecycleSourceCategory = "keptn/$PROJECT/$STAGE/$SERVICE"
+	lifecycleEventTimeout          = 1 * time.Minute
+)
+
+// lifecycleLogRecord is a Keptn event as a structured log line, e.g., to mark deployments in log searches
+type lifecycleLogRecord struct {
+	Timestamp    string            `json:"timestamp"`
+	Type         string            `json:"type"`
+	Task         string            `json:"task"`
+	Project      string            `json:"project"`
+	Stage        string            `json:"stage"`
+	Service      string            `json:"service"`
+	KeptnContext string            `json:"keptnContext"`
+	TriggeredId  string            `json:"triggeredId,omitempty"`
+	EventId      string            `json:"eventId"`
+	Source       string            `json:"source"`
+	Status       string            `json:"status,omitempty"`
+	Result       string            `json:"result,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	// Data is the complete data of the event, e.g., with the image of a deployment or the score of an evaluation
+	Data json.RawMessage `json:"data"`
+}
+
+// taskOfEventType returns the task of an event type, e.g., `deployment` for sh.keptn.event.deployment.finished
+func taskOfEventType(eventType string) string {
+	task := strings.TrimPrefix(eventType, "sh.keptn.event.")
+	if i := strings.LastIndex(task, "."); i >= 0 {
+		task = task[:i]
+	}
+	return task
+}
+
+// newLifecycleLogRecord turns the event into a log record
+func newLifecycleLogRecord(event cloudevents.Event, data *keptnv2.EventData) lifecycleLogRecord {
+	keptnContext, _ := event.Context.GetExtension("shkeptncontext")
+	triggeredId, _ := event.Context.GetExtension("triggeredid")
+
+	return lifecycleLogRecord{
+		Timestamp:    event.Time().UTC().Format(time.RFC3339Nano),
+		Type:         event.Type(),
+		Task:         taskOfEventType(event.Type()),
+		Project:      data.Project,
+		Stage:        data.Stage,
+		Service:      data.Service,
+		KeptnContext: stringExtension(keptnContext),
+		TriggeredId:  stringExtension(triggeredId),
+		EventId:      event.ID(),
+		Source:       event.Source(),
+		Status:       string(data.Status),
+		Result:       string(data.Result),
+		Message:      data.Message,
+		Labels:       data.Labels,
+		Data:         event.Data(),
+	}
+}
+
+func stringExtension(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// lifecycleSourceCategory returns the source category for the event from the template,
+// e.g., `keptn/$PROJECT/$STAGE/$SERVICE` (check expandPlaceholders)
+func lifecycleSourceCategory(template string, data *keptnv2.EventData) (string, error) {
+	if template == "" {
+		template = defaultLifecycleSourceCategory
+	}
+	return expandPlaceholders(template, map[string]string{
+		"PROJECT": data.Project,
+		"STAGE":   data.Stage,
+		"SERVICE": data.Service,
+		"project": data.Project,
+		"stage":   data.Stage,
+		"service": data.Service,
+	})
+}
+
+// sendLifecycleEvent sends the event as a JSON log line to the HTTP Source
+func sendLifecycleEvent(client *httpSourceClient, categoryTemplate string, event cloudevents.Event, data *keptnv2.EventData) error {
+	category, err := lifecycleSourceCategory(categoryTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	record := newLifecycleLogRecord(event, data)
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lifecycleEventTimeout)
+	defer cancel()
+
+	return client.send(ctx, httpSourceLogsContentType, httpSourceMetadata{
+		category: category,
+		name:     event.Type(),
+		host:     ServiceName,
+		fields: map[string]string{
+			"project":      data.Project,
+			"stage":        data.Stage,
+			"service":      data.Service,
+			"keptnContext": record.KeptnContext,
+		},
+	}, body)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+func TestSendLifecycleEvent(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	data := &keptnv2.EventData{
+		Project: "sockshop",
+		Stage:   "staging",
+		Service: "carts",
+		Status:  keptnv2.StatusSucceeded,
+		Result:  keptnv2.ResultPass,
+		Labels:  map[string]string{"buildId": "1.2.3"},
+	}
+	event := cloudevents.NewEvent()
+	event.SetID("event-1")
+	event.SetType(keptnv2.GetFinishedEventType(keptnv2.DeploymentTaskName))
+	event.SetSource("helm-service")
+	event.SetTime(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	event.SetExtension("shkeptncontext", "context-1")
+	event.SetData(cloudevents.ApplicationJSON, map[string]interface{}{"project": "sockshop", "deployment": map[string]interface{}{"deploymentstrategy": "blue_green_service"}})
+
+	err := sendLifecycleEvent(newHTTPSourceClient(server.URL), "keptn/$PROJECT/$STAGE/$SERVICE", event, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Header.Get("X-Sumo-Category") != "keptn/sockshop/staging/carts" {
+		t.Errorf("Unexpected source category %s", req.Header.Get("X-Sumo-Category"))
+	}
+	if req.Header.Get("X-Sumo-Fields") != "keptnContext=context-1,project=sockshop,service=carts,stage=staging" {
+		t.Errorf("Unexpected fields %s", req.Header.Get("X-Sumo-Fields"))
+	}
+
+	record := lifecycleLogRecord{}
+	if err := json.Unmarshal(body, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Task != keptnv2.DeploymentTaskName || record.KeptnContext != "context-1" || record.Result != "pass" || record.Timestamp != "2022-06-01T12:00:00Z" {
+		t.Errorf("Unexpected log record %s", body)
+	}
+	if record.Labels["buildId"] != "1.2.3" || len(record.Data) == 0 {
+		t.Errorf("Expected the labels and the data of the event in the log record, but got %s", body)
+	}
+}
+
+func TestSendLifecycleEventError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	event := cloudevents.NewEvent()
+	event.SetType(keptnv2.GetFinishedEventType(keptnv2.TestTaskName))
+	err := sendLifecycleEvent(newHTTPSourceClient(server.URL), "", event, &keptnv2.EventData{Project: "sockshop", Stage: "staging", Service: "carts"})
+	if err == nil {
+		t.Errorf("Expected an error for the rejected request")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+const (
+	// actionLogSnapshot summarises the top errors in the logs of the service (it doesn't change anything in Sumo Logic)
+	actionLogSnapshot = "sumologic-log-snapshot"
+
+	// defaultLogSnapshotQuery is the scope of the log search for the logs of the Sumo Logic Kubernetes Collection
+	defaultLogSnapshotQuery  = "namespace=$PROJECT-$STAGE container=$SERVICE (error OR exception OR fatal)"
+	defaultLogSnapshotWindow = 30 * time.Minute
+	defaultLogSnapshotLimit  = 5
+
+	logSnapshotModeLogReduce = "logreduce"
+	logSnapshotModeCount     = "count"
+
+	// logSnapshotMessageLength is the max length of a message in the summary (the Bridge shows the labels in one line)
+	logSnapshotMessageLength = 200
+
+	// the labels of the action.finished event with the summary
+	logSearchLabel = "sumologicLogSearch"
+	topErrorLabel  = "sumologicTopError"
+	// triggerTimeLabel is set by the webhook for the remediations triggered by Sumo Logic alerts
+	triggerTimeLabel = "sumologicTriggerTime"
+)
+
+// triggerTimeLayouts are the formats of {{TriggerTime}} in the webhook payload (besides RFC 3339 and Unix timestamps)
+var triggerTimeLayouts = []string{
+	"01/02/2006 03:04:05 PM MST",
+	"2006-01-02 15:04:05 MST",
+}
+
+// logSnapshot is the summary of the errors in the logs of the service during the problem
+type logSnapshot struct {
+	Query     string            `json:"query"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	SearchURL string            `json:"searchUrl"`
+	Total     int               `json:"total"`
+	TopErrors []logSnapshotItem `json:"topErrors"`
+}
+
+// logSnapshotItem is an error message (or a LogReduce signature) and how often it occurred
+type logSnapshotItem struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// logSnapshotQuery returns the log search for the mode (LogReduce clusters similar messages, count groups identical ones)
+func logSnapshotQuery(scope, mode string) (string, error) {
+	switch mode {
+	case "", logSnapshotModeLogReduce:
+		return scope + " | logreduce", nil
+	case logSnapshotModeCount:
+		return scope + " | count by _raw", nil
+	}
+	return "", fmt.Errorf("invalid mode `%s` (should be %s or %s)", mode, logSnapshotModeLogReduce, logSnapshotModeCount)
+}
+
+// parseTriggerTime parses the trigger time of the alert from the webhook
+func parseTriggerTime(triggerTime string) (time.Time, error) {
+	if t, err := parseUnixTimestamp(triggerTime); err == nil {
+		return t, nil
+	}
+	for _, layout := range triggerTimeLayouts {
+		if t, err := time.Parse(layout, triggerTime); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported trigger time `%s`", triggerTime)
+}
+
+// summariseLogRecords returns the messages with the highest counts of the LogReduce or count records
+func summariseLogRecords(records []map[string]string, limit int) (int, []logSnapshotItem, error) {
+	total := 0
+	items := []logSnapshotItem{}
+	for _, record := range records {
+		count, err := strconv.Atoi(record["_count"])
+		if err != nil {
+			return 0, nil, fmt.Errorf("log search record without a numeric `_count`: %v", record)
+		}
+		message, ok := record["_signature"]
+		if !ok {
+			message = record["_raw"]
+		}
+		total += count
+		items = append(items, logSnapshotItem{Message: strings.TrimSpace(message), Count: count})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Count > items[j].Count
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return total, items, nil
+}
+
+// logSnapshot searches the logs of the service from `window` before the alert (or now) until now
+func (r *actionRunner) logSnapshot(ctx context.Context, data *keptnv2.ActionTriggeredEventData, params actionParams) (*logSnapshot, error) {
+	window := defaultLogSnapshotWindow
+	if params.Window != "" {
+		var err error
+		window, err = time.ParseDuration(params.Window)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid window `%s` (should be a Go duration, e.g., `30m`)", params.Window)
+		}
+	}
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLogSnapshotLimit
+	}
+
+	to := r.now()
+	from := to.Add(-window)
+	if triggerTime, ok := data.Labels[triggerTimeLabel]; ok {
+		if t, err := parseTriggerTime(triggerTime); err == nil && t.Before(to) {
+			from = t.Add(-window)
+		}
+	}
+
+	scope := params.Query
+	if scope == "" {
+		scope = r.logSnapshotQuery
+	}
+	scope, err := expandPlaceholders(scope, newPlaceholderValues(&keptnv2.GetSLITriggeredEventData{EventData: data.EventData}, from, to))
+	if err != nil {
+		return nil, err
+	}
+	query, err := logSnapshotQuery(scope, params.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := r.search.runSearchJob(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	total, topErrors, err := summariseLogRecords(records, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logSnapshot{
+		Query:     query,
+		From:      from.UTC(),
+		To:        to.UTC(),
+		SearchURL: logSearchURL(r.uiURL, query, from, to),
+		Total:     total,
+		TopErrors: topErrors,
+	}, nil
+}
+
+// message summarises the top errors for the action.finished event
+func (s *logSnapshot) message() string {
+	if s.Total == 0 {
+		return fmt.Sprintf("found no errors between %s and %s (%s)", s.From.Format(time.RFC3339), s.To.Format(time.RFC3339), s.SearchURL)
+	}
+
+	lines := []string{fmt.Sprintf("found %d errors between %s and %s, top errors:", s.Total, s.From.Format(time.RFC3339), s.To.Format(time.RFC3339))}
+	for i, item := range s.TopErrors {
+		lines = append(lines, fmt.Sprintf("%d. (%dx) %s", i+1, item.Count, truncate(item.Message, logSnapshotMessageLength)))
+	}
+	lines = append(lines, "Log search: "+s.SearchURL)
+	return strings.Join(lines, "\n")
+}
+
+// labels are the link to the search and the top error for the Bridge
+func (s *logSnapshot) labels() map[string]string {
+	labels := map[string]string{logSearchLabel: s.SearchURL}
+	if len(s.TopErrors) > 0 {
+		labels[topErrorLabel] = fmt.Sprintf("(%dx) %s", s.TopErrors[0].Count, truncate(s.TopErrors[0].Message, logSnapshotMessageLength))
+	}
+	return labels
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
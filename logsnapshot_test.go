@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+func TestLogSnapshot(t *testing.T) {
+	var req searchJobRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/search/jobs":
+			json.NewDecoder(r.Body).Decode(&req)
+			w.Write([]byte(`{"id": "job-1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/search/jobs/job-1":
+			w.Write([]byte(`{"state": "DONE GATHERING RESULTS", "recordCount": 3}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/search/jobs/job-1/records":
+			w.Write([]byte(`{"records": [
+				{"map": {"_signature": "timeout calling $DATE orders", "_count": "3"}},
+				{"map": {"_signature": "connection refused by db", "_count": "12"}},
+				{"map": {"_signature": "out of memory", "_count": "1"}}
+			]}`))
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	runner := &actionRunner{
+		search:           newSearchJobClient(server.URL, "id", "key"),
+		uiURL:            "https://service.eu.sumologic.com",
+		logSnapshotQuery: defaultLogSnapshotQuery,
+		now:              func() time.Time { return now },
+	}
+
+	data := &keptnv2.ActionTriggeredEventData{
+		EventData: keptnv2.EventData{
+			Project: "sockshop",
+			Stage:   "production",
+			Service: "carts",
+			Labels:  map[string]string{triggerTimeLabel: "2022-06-01T11:50:00Z"},
+		},
+		Action: keptnv2.ActionInfo{Action: actionLogSnapshot, Value: map[string]interface{}{"window": "10m", "limit": 2}},
+	}
+	result, err := runner.run(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQuery := "namespace=sockshop-production container=carts (error OR exception OR fatal) | logreduce"
+	if req.Query != expectedQuery || req.From != "2022-06-01T11:40:00" || req.To != "2022-06-01T12:00:00" {
+		t.Errorf("Unexpected search job %+v", req)
+	}
+
+	snapshot := result.Snapshot
+	if snapshot.Total != 16 || len(snapshot.TopErrors) != 2 || snapshot.TopErrors[0].Message != "connection refused by db" {
+		t.Errorf("Unexpected snapshot %+v", snapshot)
+	}
+	if !strings.HasPrefix(snapshot.SearchURL, "https://service.eu.sumologic.com/ui/#/search/create?query=namespace%3Dsockshop-production") ||
+		!strings.HasSuffix(snapshot.SearchURL, "&startTime=1654083600000&endTime=1654084800000") {
+		t.Errorf("Unexpected search URL %s", snapshot.SearchURL)
+	}
+
+	labels := result.labels()
+	if labels[topErrorLabel] != "(12x) connection refused by db" || labels[logSearchLabel] != snapshot.SearchURL {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+	if !strings.Contains(result.message(), "1. (12x) connection refused by db\n2. (3x) timeout calling $DATE orders") {
+		t.Errorf("Unexpected message %s", result.message())
+	}
+}
+
+func TestParseTriggerTime(t *testing.T) {
+	expected := time.Date(2022, 6, 1, 13, 4, 5, 0, time.UTC)
+	for _, triggerTime := range []string{"2022-06-01T13:04:05Z", "1654088645", "06/01/2022 01:04:05 PM UTC", "2022-06-01 13:04:05 UTC"} {
+		parsed, err := parseTriggerTime(triggerTime)
+		if err != nil || !parsed.Equal(expected) {
+			t.Errorf("Expected %s for %s, but got %s (%v)", expected, triggerTime, parsed, err)
+		}
+	}
+}
+
+func TestSumoUIURL(t *testing.T) {
+	for endpoint, expected := range map[string]string{
+		"https://api.sumologic.com/api":    "https://service.sumologic.com",
+		"https://api.eu.sumologic.com/api": "https://service.eu.sumologic.com",
+		"http://127.0.0.1:8080":            "http://127.0.0.1:8080",
+	} {
+		if url := sumoUIURL(endpoint); url != expected {
+			t.Errorf("Expected %s for %s, but got %s", expected, endpoint, url)
+		}
+	}
+}
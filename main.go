@@ -11,8 +11,10 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/keptn-sandbox/sumologic-service/pkg/utils"
 	keptnlib "github.com/keptn/go-utils/pkg/lib"
@@ -47,6 +49,44 @@ type envConfig struct {
 	// If you don't know the region code for your Sumo Logic
 	// check https://api.sumologic.com/docs/#section/Getting-Started/API-Endpoints
 	SumoEndPt string `envconfig:"SUMO_END_PT" default:"https://api.sumologic.com/api"`
+	// GetSLITimeout is the time budget of a get-sli.triggered event (all the queries, retries and polls included)
+	GetSLITimeout time.Duration `envconfig:"GET_SLI_TIMEOUT" default:"10m"`
+	// SLIConcurrency is the max number of indicators fetched from Sumo Logic in parallel
+	SLIConcurrency int `envconfig:"SLI_CONCURRENCY" default:"4"`
+	// DataReadinessTimeout is how long to wait for the metrics data in Sumo Logic to be complete
+	DataReadinessTimeout time.Duration `envconfig:"DATA_READINESS_TIMEOUT" default:"5m"`
+	// DataPollInterval is how often to query Sumo Logic while waiting for the metrics data to be complete
+	DataPollInterval time.Duration `envconfig:"DATA_POLL_INTERVAL" default:"15s"`
+	// SearchJobTimeout is how long to wait for a log search (Search Job API) to finish
+	SearchJobTimeout time.Duration `envconfig:"SEARCH_JOB_TIMEOUT" default:"5m"`
+	// APIMaxRetries is how often a throttled (429) or failed (5xx) Sumo Logic API request is retried
+	APIMaxRetries int `envconfig:"API_MAX_RETRIES" default:"4"`
+	// APIRetryBaseDelay is the backoff before the first retry (doubled with every retry, with jitter)
+	APIRetryBaseDelay time.Duration `envconfig:"API_RETRY_BASE_DELAY" default:"1s"`
+	// APIRetryBudget is the max time a Sumo Logic API request can take with all its retries
+	APIRetryBudget time.Duration `envconfig:"API_RETRY_BUDGET" default:"2m"`
+	// APIRateLimit is the max number of Sumo Logic API requests per second of the service (no limit if 0)
+	APIRateLimit float64 `envconfig:"API_RATE_LIMIT" default:"4"`
+	// APIMaxConcurrent is the max number of concurrent Sumo Logic API requests of the service (no limit if 0)
+	APIMaxConcurrent int `envconfig:"API_MAX_CONCURRENT" default:"10"`
+	// AppendCustomFilters appends the customFilters of get-sli.triggered to every query (can be overridden per indicator)
+	AppendCustomFilters bool `envconfig:"APPEND_CUSTOM_FILTERS" default:"false"`
+	// MonitorEvaluationWindow is the time range the monitors created by configure-monitoring evaluate
+	MonitorEvaluationWindow time.Duration `envconfig:"MONITOR_EVALUATION_WINDOW" default:"15m"`
+	// WebhookToken is the token Sumo Logic webhook connections send as `Authorization: Bearer <token>` (webhook disabled if empty)
+	WebhookToken string `envconfig:"WEBHOOK_TOKEN" default:""`
+	// WebhookDedupWindow is how long repeated notifications of an alert are dropped after triggering a remediation
+	WebhookDedupWindow time.Duration `envconfig:"WEBHOOK_DEDUP_WINDOW" default:"30m"`
+	// HTTPSourceURL is the URL of the Sumo Logic HTTP Source the Keptn events are sent to (not sent if empty)
+	HTTPSourceURL string `envconfig:"HTTP_SOURCE_URL" default:""`
+	// LifecycleSourceCategory is the source category of the Keptn events, e.g., `keptn/$PROJECT/$STAGE/$SERVICE`
+	LifecycleSourceCategory string `envconfig:"LIFECYCLE_SOURCE_CATEGORY" default:"keptn/$PROJECT/$STAGE/$SERVICE"`
+	// MetricsSourceURL is the URL of the Sumo Logic HTTP Source the quality gate results are sent to as metrics (not sent if empty)
+	MetricsSourceURL string `envconfig:"METRICS_SOURCE_URL" default:""`
+	// MetricsFormat is the format of the quality gate metrics, either prometheus or carbon2
+	MetricsFormat string `envconfig:"METRICS_FORMAT" default:"prometheus"`
+	// LogSnapshotQuery is the default scope of the log search of the sumologic-log-snapshot action
+	LogSnapshotQuery string `envconfig:"LOG_SNAPSHOT_QUERY" default:"namespace=$PROJECT-$STAGE container=$SERVICE (error OR exception OR fatal)"`
 }
 
 // ServiceName specifies the current services name (e.g., used as source when sending CloudEvents)
@@ -143,7 +183,7 @@ func processKeptnCloudEvent(ctx context.Context, event cloudevents.Event) error
 		eventData := &keptnv2.GetSLITriggeredEventData{}
 		parseKeptnCloudEventPayload(event, eventData)
 
-		return HandleGetSliTriggeredEvent(myKeptn, event, eventData)
+		return HandleGetSliTriggeredEvent(ctx, myKeptn, event, eventData)
 
 	case keptnv2.GetTriggeredEventType(keptnv2.ConfigureMonitoringTaskName): // sh.keptn.event.configure-monitoring.triggered
 		log.Printf("Processing configure-monitoring.Triggered Event")
@@ -152,6 +192,38 @@ func processKeptnCloudEvent(ctx context.Context, event cloudevents.Event) error
 		parseKeptnCloudEventPayload(event, eventData)
 
 		return HandleConfigureMonitoringTriggeredEvent(myKeptn, event, eventData)
+
+	// -------------------------------------------------------
+	// sh.keptn.event.action (sent by remediation sequences)
+	case keptnv2.GetTriggeredEventType(keptnv2.ActionTaskName): // sh.keptn.event.action.triggered
+		log.Printf("Processing Action.Triggered Event")
+
+		eventData := &keptnv2.ActionTriggeredEventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleActionTriggeredEvent(myKeptn, event, eventData)
+
+	// -------------------------------------------------------
+	// sh.keptn.event.{deployment,test,release}.finished (sent to the Sumo Logic HTTP Source)
+	case keptnv2.GetFinishedEventType(keptnv2.DeploymentTaskName),
+		keptnv2.GetFinishedEventType(keptnv2.TestTaskName),
+		keptnv2.GetFinishedEventType(keptnv2.ReleaseTaskName):
+		log.Printf("Processing %s Event", event.Type())
+
+		eventData := &keptnv2.EventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleTaskFinishedEvent(myKeptn, event, eventData)
+
+	// -------------------------------------------------------
+	// sh.keptn.event.evaluation.finished (sent to the Sumo Logic HTTP Source, also as quality gate metrics)
+	case keptnv2.GetFinishedEventType(keptnv2.EvaluationTaskName):
+		log.Printf("Processing Evaluation.Finished Event")
+
+		eventData := &keptnv2.EvaluationFinishedEventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleEvaluationFinishedEvent(myKeptn, event, eventData)
 	}
 
 	// Unknown Event -> Throw Error!
@@ -215,14 +287,28 @@ func _main(args []string, env envConfig) int {
 		env.SumoEndPt = fmt.Sprintf("https://api.%s.sumologic.com/api", env.RegionCode)
 	}
 
+	// all the requests to the Sumo Logic API share the quota of the access key
+	sumoAPILimiter = newAPILimiter(env.APIRateLimit, env.APIMaxConcurrent)
+
 	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
 	log.Printf("Creating new http handler")
 
-	// configure http server to receive cloudevents
-	p, err := cloudevents.NewHTTP(
-		cloudevents.WithPath(env.Path), cloudevents.WithPort(env.Port), cloudevents.WithGetHandlerFunc(HTTPGetHandler),
-	)
+	// the remediations triggered by Sumo Logic alerts are sent through the distributor
+	eventSender, err := keptnv2.NewHTTPEventSender("")
+	if err != nil {
+		log.Fatalf("failed to create event sender, %v", err)
+	}
+	// configure http server to receive cloudevents (and the Sumo Logic webhook notifications)
+	opts := []cehttp.Option{cloudevents.WithPath(env.Path), cloudevents.WithPort(env.Port), cloudevents.WithGetHandlerFunc(HTTPGetHandler)}
+	// the webhook triggers remediations (which change monitors and alerts), so it needs a token
+	if env.WebhookToken != "" {
+		webhook := newWebhookHandler(env.WebhookToken, env.WebhookDedupWindow, eventSender)
+		opts = append(opts, cloudevents.WithMiddleware(webhook.middleware))
+	} else {
+		log.Printf("WEBHOOK_TOKEN is not set, the Sumo Logic webhook endpoint %s is disabled", webhookPath)
+	}
+	p, err := cloudevents.NewHTTP(opts...)
 
 	if err != nil {
 		log.Fatalf("failed to create client, %v", err)
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	keptn "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sloFile = "slo.yaml"
+
+	// monitoringType is the type of configure-monitoring events meant for this service
+	monitoringType = "sumologic"
+
+	defaultMonitorEvaluationWindow = 15 * time.Minute
+	configureMonitoringTimeout     = 5 * time.Minute
+
+	// the dashboard variables which replace $STAGE and $SERVICE in the queries of the panels
+	dashboardStageVariable   = "stage"
+	dashboardServiceVariable = "service"
+
+	// the panels are laid out in 2 columns on the grid of 24 columns
+	dashboardPanelWidth  = 12
+	dashboardPanelHeight = 8
+
+	thresholdColorPass    = "#16943E"
+	thresholdColorWarning = "#DFBE2E"
+	thresholdColorFail    = "#BF2121"
+)
+
+// sloCriterionPattern matches the absolute criteria of an SLO, e.g., `<=800`
+// (relative criteria like `<=+10%` compare with previous evaluations and can't be turned into a monitor)
+var sloCriterionPattern = regexp.MustCompile(`^(<=|>=|<|>)\s*([0-9]+(?:\.[0-9]+)?)$`)
+
+// monitorDescriptionPattern matches the project, stage and service in the description of a monitor (check monitorDescription)
+var monitorDescriptionPattern = regexp.MustCompile(`of ([^/\s]+)/([^/\s]+)/([^/\s]+) \(changes are overwritten`)
+
+// configureMonitoringFinishedEventData is the configure-monitoring.finished event with the monitors in Sumo Logic
+type configureMonitoringFinishedEventData struct {
+	keptnv2.EventData
+	SumoLogic monitoringResult `json:"sumologic"`
+}
+
+type monitoringResult struct {
+	// FolderId is the id of the project folder in the monitors library
+	FolderId string `json:"folderId,omitempty"`
+	// MonitorIds are the ids of the created or updated monitors by monitor name
+	MonitorIds map[string]string `json:"monitorIds,omitempty"`
+	// DashboardId is the id of the created or updated dashboard of the service
+	DashboardId string `json:"dashboardId,omitempty"`
+}
+
+// stageMonitoringConfig is the slo.yaml (nil if there is none) and the sumologic/sli.yaml of the service in a stage
+type stageMonitoringConfig struct {
+	stage      string
+	slo        *keptn.ServiceLevelObjectives
+	indicators map[string]indicatorConfig
+}
+
+// sloCriterion is an absolute criterion of an SLO
+type sloCriterion struct {
+	operator  string
+	threshold float64
+}
+
+// parseSLOCriterion parses an absolute criterion (false for relative criteria)
+func parseSLOCriterion(criterion string) (sloCriterion, bool) {
+	match := sloCriterionPattern.FindStringSubmatch(criterion)
+	if match == nil {
+		return sloCriterion{}, false
+	}
+	threshold, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return sloCriterion{}, false
+	}
+	return sloCriterion{operator: match[1], threshold: threshold}, true
+}
+
+// firstAbsoluteCriterion returns the first absolute criterion of the criteria
+// (a monitor has a single threshold per trigger type)
+func firstAbsoluteCriterion(criteria []*keptn.SLOCriteria) (sloCriterion, bool) {
+	for _, c := range criteria {
+		if c == nil {
+			continue
+		}
+		for _, criterion := range c.Criteria {
+			if parsed, ok := parseSLOCriterion(criterion); ok {
+				return parsed, true
+			}
+		}
+	}
+	return sloCriterion{}, false
+}
+
+// violatedThresholdType is the threshold type of a trigger that fires when the criterion is violated
+func (c sloCriterion) violatedThresholdType() string {
+	switch c.operator {
+	case "<=":
+		return "GreaterThan"
+	case "<":
+		return "GreaterThanOrEqual"
+	case ">=":
+		return "LessThan"
+	default:
+		return "LessThanOrEqual"
+	}
+}
+
+// metThresholdType is the threshold type of a trigger that resolves when the criterion is met again
+func (c sloCriterion) metThresholdType() string {
+	switch c.operator {
+	case "<=":
+		return "LessThanOrEqual"
+	case "<":
+		return "LessThan"
+	case ">=":
+		return "GreaterThanOrEqual"
+	default:
+		return "GreaterThan"
+	}
+}
+
+// monitorTriggers mirrors the SLO criteria: violating the warning criteria (or the pass criteria if there are
+// no warning criteria) is critical, violating only the pass criteria is a warning
+func monitorTriggers(pass sloCriterion, warning *sloCriterion, window time.Duration) []monitorTrigger {
+	trigger := func(triggerType string, c sloCriterion, thresholdType string) monitorTrigger {
+		return monitorTrigger{
+			DetectionMethod: "StaticCondition",
+			TriggerType:     triggerType,
+			Threshold:       c.threshold,
+			ThresholdType:   thresholdType,
+			TimeRange:       fmt.Sprintf("-%dm", int64(window.Minutes())),
+			OccurrenceType:  "Always",
+			TriggerSource:   "AnyTimeSeries",
+		}
+	}
+
+	if warning == nil {
+		return []monitorTrigger{
+			trigger("Critical", pass, pass.violatedThresholdType()),
+			trigger("ResolvedCritical", pass, pass.metThresholdType()),
+		}
+	}
+	return []monitorTrigger{
+		trigger("Critical", *warning, warning.violatedThresholdType()),
+		trigger("ResolvedCritical", *warning, warning.metThresholdType()),
+		trigger("Warning", pass, pass.violatedThresholdType()),
+		trigger("ResolvedWarning", pass, pass.metThresholdType()),
+	}
+}
+
+// monitorName is the name of the monitor of an SLO objective, e.g., `carts staging: response_time_p95`
+func monitorName(stage, service string, objective *keptn.SLO) string {
+	name := objective.SLI
+	if objective.DisplayName != "" {
+		name = objective.DisplayName
+	}
+	return fmt.Sprintf("%s %s: %s", service, stage, name)
+}
+
+// monitorDescription is the description of the monitor of an SLO objective
+// (the webhook gets the project, stage and service of an alert from it)
+func monitorDescription(project, stage, service string) string {
+	return fmt.Sprintf("Created by Keptn from %s of %s/%s/%s (changes are overwritten by configure-monitoring)", sloFile, project, stage, service)
+}
+
+// buildMonitors creates a metrics monitor for every objective of the SLO with absolute pass criteria.
+// It returns the monitors and the reasons why objectives were skipped.
+func buildMonitors(project, stage, service string, slo *keptn.ServiceLevelObjectives, indicators map[string]indicatorConfig, window time.Duration) ([]monitor, []string) {
+	monitors := []monitor{}
+	skipped := []string{}
+
+	// the placeholders are resolved for an evaluation of the monitor window
+	data := &keptnv2.GetSLITriggeredEventData{
+		EventData: keptnv2.EventData{Project: project, Stage: stage, Service: service},
+	}
+	end := time.Now()
+	start := end.Add(-window)
+
+	for _, objective := range slo.Objectives {
+		if objective == nil || objective.SLI == "" {
+			continue
+		}
+		skip := func(reason string) {
+			skipped = append(skipped, fmt.Sprintf("%s in %s: %s", objective.SLI, stage, reason))
+		}
+
+		indicator, ok := indicators[objective.SLI]
+		if !ok {
+			indicator, ok = defaultIndicators[objective.SLI]
+		}
+		if !ok {
+			skip("indicator is not configured")
+			continue
+		}
+		if indicator.Type != indicatorTypeMetrics {
+			skip("only metrics indicators can be turned into monitors")
+			continue
+		}
+		if r, err := parseReducer(indicator.Reducer); err == nil && r.histogram != nil {
+			skip("histogram percentiles can't be turned into monitors")
+			continue
+		}
+
+		pass, ok := firstAbsoluteCriterion(objective.Pass)
+		if !ok {
+			skip("no absolute pass criteria")
+			continue
+		}
+		var warning *sloCriterion
+		if w, ok := firstAbsoluteCriterion(objective.Warning); ok {
+			warning = &w
+		}
+
+		queries, err := monitorQueries(data, indicator, start, end)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+
+		monitors = append(monitors, monitor{
+			Name:        monitorName(stage, service, objective),
+			Description: monitorDescription(project, stage, service),
+			MonitorType: "Metrics",
+			Queries:     queries,
+			Triggers:    monitorTriggers(pass, warning, window),
+		})
+	}
+
+	return monitors, skipped
+}
+
+// monitorQueries returns the rows of the indicator with the placeholders replaced (the value row comes last
+// since the triggers of a monitor apply to the last row)
+func monitorQueries(data *keptnv2.GetSLITriggeredEventData, indicator indicatorConfig, start, end time.Time) ([]monitorQuery, error) {
+	rows, valueRow := indicator.metricsRows()
+	ids := []string{}
+	for id := range rows {
+		if id != valueRow {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	ids = append(ids, valueRow)
+
+	queries := []monitorQuery{}
+	for _, id := range ids {
+		query, err := replaceQueryParameters(data, rows[id], start, end)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, monitorQuery{RowId: id, Query: query})
+	}
+	return queries, nil
+}
+
+// panelThreshold is a colored range of values in a dashboard panel (from and to are open if they are nil)
+type panelThreshold struct {
+	From  *float64 `json:"from"`
+	To    *float64 `json:"to"`
+	Color string   `json:"color"`
+}
+
+// panelThresholds mirrors the SLO criteria as ranges: meeting the pass criteria is green,
+// violating only the pass criteria is yellow and violating the warning criteria is red
+func panelThresholds(pass sloCriterion, warning *sloCriterion) []panelThreshold {
+	p := pass.threshold
+	// for criteria like `<=800` the values below the threshold pass, for criteria like `>=10` the values above
+	lowerIsBetter := strings.HasPrefix(pass.operator, "<")
+
+	if warning == nil {
+		if lowerIsBetter {
+			return []panelThreshold{{To: &p, Color: thresholdColorPass}, {From: &p, Color: thresholdColorFail}}
+		}
+		return []panelThreshold{{From: &p, Color: thresholdColorPass}, {To: &p, Color: thresholdColorFail}}
+	}
+
+	w := warning.threshold
+	if lowerIsBetter {
+		return []panelThreshold{{To: &p, Color: thresholdColorPass}, {From: &p, To: &w, Color: thresholdColorWarning}, {From: &w, Color: thresholdColorFail}}
+	}
+	return []panelThreshold{{From: &p, Color: thresholdColorPass}, {From: &w, To: &p, Color: thresholdColorWarning}, {To: &w, Color: thresholdColorFail}}
+}
+
+// panelVisualSettings returns the visual settings of a time series panel with the thresholds (if any)
+func panelVisualSettings(thresholds []panelThreshold) (string, error) {
+	settings := map[string]interface{}{
+		"general": map[string]string{"mode": "timeSeries", "type": "line"},
+	}
+	if len(thresholds) > 0 {
+		settings["thresholdsSettings"] = map[string]bool{"showThresholds": true, "fillRemainingGreen": false}
+		settings["thresholds"] = thresholds
+	}
+
+	b, err := json.Marshal(settings)
+	return string(b), err
+}
+
+// dashboardTitle is the title of the dashboard of a service, e.g., `Keptn sockshop: carts`
+func dashboardTitle(project, service string) string {
+	return fmt.Sprintf("Keptn %s: %s", project, service)
+}
+
+// buildDashboard creates a dashboard for the service with a panel per indicator in sumologic/sli.yaml
+// (and per default indicator used in slo.yaml) and the variables stage and service.
+// The queries and the thresholds of a panel come from the first stage which defines the indicator and its objective.
+// It returns the dashboard and the reasons why indicators were skipped.
+func buildDashboard(project, service string, stages []stageMonitoringConfig, window time.Duration) (dashboard, []string) {
+	skipped := []string{}
+
+	// the variables are used instead of the stage and the service so a single dashboard works for all the stages
+	data := &keptnv2.GetSLITriggeredEventData{
+		EventData: keptnv2.EventData{
+			Project: project,
+			Stage:   "{{" + dashboardStageVariable + "}}",
+			Service: "{{" + dashboardServiceVariable + "}}",
+		},
+	}
+	end := time.Now()
+	start := end.Add(-window)
+
+	indicators := map[string]indicatorConfig{}
+	titles := map[string]string{}
+	thresholds := map[string][]panelThreshold{}
+	stageNames := []string{}
+	for _, s := range stages {
+		stageNames = append(stageNames, s.stage)
+		for name, indicator := range s.indicators {
+			if _, ok := indicators[name]; !ok {
+				indicators[name] = indicator
+			}
+		}
+		if s.slo == nil {
+			continue
+		}
+		for _, objective := range s.slo.Objectives {
+			if objective == nil || objective.SLI == "" {
+				continue
+			}
+			if _, ok := indicators[objective.SLI]; !ok {
+				if indicator, ok := defaultIndicators[objective.SLI]; ok {
+					indicators[objective.SLI] = indicator
+				}
+			}
+			if _, ok := titles[objective.SLI]; !ok && objective.DisplayName != "" {
+				titles[objective.SLI] = objective.DisplayName
+			}
+			if _, ok := thresholds[objective.SLI]; ok {
+				continue
+			}
+			if pass, ok := firstAbsoluteCriterion(objective.Pass); ok {
+				var warning *sloCriterion
+				if w, ok := firstAbsoluteCriterion(objective.Warning); ok {
+					warning = &w
+				}
+				thresholds[objective.SLI] = panelThresholds(pass, warning)
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	d := dashboard{
+		Title:            dashboardTitle(project, service),
+		Description:      fmt.Sprintf("Created by Keptn from %s and %s of %s/%s (changes are overwritten by configure-monitoring)", sliFile, sloFile, project, service),
+		TopologyLabelMap: dashboardTopology{Data: map[string][]string{}},
+		TimeRange: dashboardTimeRange{
+			Type: "BeginBoundedTimeRange",
+			From: dashboardTimeRangeBoundary{Type: "RelativeTimeRangeBoundary", RelativeTime: fmt.Sprintf("-%dm", int64(window.Minutes()))},
+		},
+		Panels: []dashboardPanel{},
+		Layout: dashboardLayout{LayoutType: dashboardLayoutType, LayoutStructures: []dashboardLayoutStructure{}},
+		Variables: []dashboardVariable{
+			csvVariable(dashboardStageVariable, stageNames),
+			csvVariable(dashboardServiceVariable, []string{service}),
+		},
+		Theme: "Light",
+	}
+
+	for _, name := range names {
+		indicator := indicators[name]
+		skip := func(reason string) {
+			skipped = append(skipped, fmt.Sprintf("panel %s: %s", name, reason))
+		}
+
+		queries := []dashboardQuery{}
+		if indicator.Type == indicatorTypeLogs {
+			query, err := replaceQueryParameters(data, indicator.Query, start, end)
+			if err != nil {
+				skip(err.Error())
+				continue
+			}
+			queries = append(queries, dashboardQuery{QueryKey: defaultRowID, QueryString: query, QueryType: "Logs"})
+		} else {
+			rows, err := monitorQueries(data, indicator, start, end)
+			if err != nil {
+				skip(err.Error())
+				continue
+			}
+			for _, row := range rows {
+				queries = append(queries, dashboardQuery{QueryKey: row.RowId, QueryString: row.Query, QueryType: "Metrics", MetricsQueryMode: "Advanced"})
+			}
+		}
+
+		visualSettings, err := panelVisualSettings(thresholds[name])
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+
+		title := name
+		if displayName, ok := titles[name]; ok {
+			title = displayName
+		}
+
+		i := len(d.Panels)
+		key := fmt.Sprintf("panel%d", i)
+		d.Panels = append(d.Panels, dashboardPanel{
+			Key:            key,
+			Title:          title,
+			PanelType:      dashboardPanelType,
+			Queries:        queries,
+			VisualSettings: visualSettings,
+
+			KeepVisualSettingsConsistentWithParent: true,
+		})
+		d.Layout.LayoutStructures = append(d.Layout.LayoutStructures, dashboardLayoutStructure{
+			Key:       key,
+			Structure: fmt.Sprintf(`{"height":%d,"width":%d,"x":%d,"y":%d}`, dashboardPanelHeight, dashboardPanelWidth, (i%2)*dashboardPanelWidth, (i/2)*dashboardPanelHeight),
+		})
+	}
+
+	return d, skipped
+}
+
+// csvVariable is a dashboard variable with a fixed list of values (the first value is the default)
+func csvVariable(name string, values []string) dashboardVariable {
+	defaultValue := ""
+	if len(values) > 0 {
+		defaultValue = values[0]
+	}
+	return dashboardVariable{
+		Name:         name,
+		DisplayName:  name,
+		DefaultValue: defaultValue,
+		SourceDefinition: dashboardVariableSource{
+			VariableSourceType: "CsvVariableSourceDefinition",
+			Values:             strings.Join(values, ","),
+		},
+	}
+}
+
+// getStageMonitoringConfigs returns the slo.yaml and sumologic/sli.yaml of the service in every stage
+// (or only in the stage of the event if it has one)
+func getStageMonitoringConfigs(myKeptn *keptnv2.Keptn, data *keptnv2.ConfigureMonitoringTriggeredEventData) ([]stageMonitoringConfig, error) {
+	stages := []string{}
+	if data.Stage != "" {
+		stages = append(stages, data.Stage)
+	} else {
+		shipyard, err := myKeptn.GetShipyard()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the shipyard of %s: %w", data.Project, err)
+		}
+		for _, stage := range shipyard.Spec.Stages {
+			stages = append(stages, stage.Name)
+		}
+	}
+
+	configs := []stageMonitoringConfig{}
+	for _, stage := range stages {
+		config := stageMonitoringConfig{stage: stage}
+
+		res, err := myKeptn.ResourceHandler.GetServiceResource(data.Project, stage, data.Service, sloFile)
+		if err != nil && !isResourceNotFound(err) {
+			return nil, fmt.Errorf("failed to get %s for %s in %s: %w", sloFile, data.Service, stage, err)
+		}
+		if err == nil {
+			config.slo = &keptn.ServiceLevelObjectives{}
+			if err := yaml.Unmarshal([]byte(res.ResourceContent), config.slo); err != nil {
+				return nil, fmt.Errorf("invalid %s for %s in %s: %w", sloFile, data.Service, stage, err)
+			}
+		} else {
+			log.Infof("no %s for %s in %s, no monitors are created for the stage", sloFile, data.Service, stage)
+		}
+
+		config.indicators, err = getSLIConfiguration(myKeptn, data.Project, stage, data.Service, sliFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s for %s in %s: %w", sliFile, data.Service, stage, err)
+		}
+
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// configureMonitors creates or updates the monitors for the SLOs of the service in every stage
+// (or only the stage of the event if it has one) and the dashboard of the service
+func configureMonitors(myKeptn *keptnv2.Keptn, data *keptnv2.ConfigureMonitoringTriggeredEventData, monitors *monitorsClient, dashboards *dashboardsClient, window time.Duration) (monitoringResult, []string, error) {
+	result := monitoringResult{MonitorIds: map[string]string{}}
+
+	stages, err := getStageMonitoringConfigs(myKeptn, data)
+	if err != nil {
+		return result, nil, err
+	}
+
+	stageMonitors := []monitor{}
+	skipped := []string{}
+	for _, s := range stages {
+		if s.slo == nil {
+			continue
+		}
+		built, notBuilt := buildMonitors(data.Project, s.stage, data.Service, s.slo, s.indicators, window)
+		stageMonitors = append(stageMonitors, built...)
+		skipped = append(skipped, notBuilt...)
+	}
+
+	d, skippedPanels := buildDashboard(data.Project, data.Service, stages, window)
+	skipped = append(skipped, skippedPanels...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), configureMonitoringTimeout)
+	defer cancel()
+
+	if len(stageMonitors) > 0 {
+		folder, err := monitors.projectFolder(ctx, data.Project)
+		if err != nil {
+			return result, skipped, fmt.Errorf("failed to get the monitors folder of %s: %w", data.Project, err)
+		}
+		result.FolderId = folder.Id
+
+		for _, m := range stageMonitors {
+			id, err := monitors.upsertMonitor(ctx, folder, m)
+			if err != nil {
+				return result, skipped, fmt.Errorf("failed to save the monitor %s: %w", m.Name, err)
+			}
+			log.Infof("saved monitor %s (%s)", m.Name, id)
+			result.MonitorIds[m.Name] = id
+		}
+	}
+
+	if len(d.Panels) > 0 {
+		id, err := dashboards.upsertDashboard(ctx, d)
+		if err != nil {
+			return result, skipped, fmt.Errorf("failed to save the dashboard %s: %w", d.Title, err)
+		}
+		log.Infof("saved dashboard %s (%s)", d.Title, id)
+		result.DashboardId = id
+	}
+
+	return result, skipped, nil
+}
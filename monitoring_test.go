@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	keptn "github.com/keptn/go-utils/pkg/lib"
+)
+
+func TestBuildMonitors(t *testing.T) {
+	slo := &keptn.ServiceLevelObjectives{
+		Objectives: []*keptn.SLO{
+			{
+				SLI:     "response_time_p95",
+				Pass:    []*keptn.SLOCriteria{{Criteria: []string{"<=+10%", "<800"}}},
+				Warning: []*keptn.SLOCriteria{{Criteria: []string{"<=1000"}}},
+			},
+			{SLI: "throughput", DisplayName: "Throughput", Pass: []*keptn.SLOCriteria{{Criteria: []string{">=10"}}}},
+			{SLI: "error_count", Pass: []*keptn.SLOCriteria{{Criteria: []string{"<=5"}}}},
+			{SLI: "cpu_usage", Pass: []*keptn.SLOCriteria{{Criteria: []string{"<=+10%"}}}},
+			{SLI: "memory_usage"},
+			{SLI: "unknown", Pass: []*keptn.SLOCriteria{{Criteria: []string{"<=1"}}}},
+		},
+	}
+	indicators := map[string]indicatorConfig{
+		"response_time_p95": {Type: indicatorTypeMetrics, Query: "metric=latency service=$SERVICE namespace=$PROJECT-$STAGE | quantize to $DURATION using max | max"},
+		"error_count":       {Type: indicatorTypeLogs, Query: "error | count"},
+	}
+
+	monitors, skipped := buildMonitors("sockshop", "staging", "carts", slo, indicators, 15*time.Minute)
+
+	// response_time_p95 from sli.yaml and throughput from the default indicators
+	if len(monitors) != 2 {
+		t.Fatalf("Expected 2 monitors, but got %+v", monitors)
+	}
+	if len(skipped) != 4 {
+		t.Errorf("Expected error_count, cpu_usage, memory_usage and unknown to be skipped, but got %v", skipped)
+	}
+
+	latency := monitors[0]
+	if latency.Name != "carts staging: response_time_p95" {
+		t.Errorf("Unexpected monitor name %s", latency.Name)
+	}
+	if latency.Queries[0].Query != "metric=latency service=carts namespace=sockshop-staging | quantize to 900s using max | max" {
+		t.Errorf("Expected the placeholders to be replaced, but got %s", latency.Queries[0].Query)
+	}
+	want := []monitorTrigger{
+		{TriggerType: "Critical", Threshold: 1000, ThresholdType: "GreaterThan"},
+		{TriggerType: "ResolvedCritical", Threshold: 1000, ThresholdType: "LessThanOrEqual"},
+		{TriggerType: "Warning", Threshold: 800, ThresholdType: "GreaterThanOrEqual"},
+		{TriggerType: "ResolvedWarning", Threshold: 800, ThresholdType: "LessThan"},
+	}
+	if len(latency.Triggers) != len(want) {
+		t.Fatalf("Expected %d triggers, but got %+v", len(want), latency.Triggers)
+	}
+	for i, trigger := range latency.Triggers {
+		if trigger.TriggerType != want[i].TriggerType || trigger.Threshold != want[i].Threshold || trigger.ThresholdType != want[i].ThresholdType || trigger.TimeRange != "-15m" {
+			t.Errorf("Expected trigger %+v, but got %+v", want[i], trigger)
+		}
+	}
+
+	throughput := monitors[1]
+	if throughput.Name != "carts staging: Throughput" || len(throughput.Triggers) != 2 || throughput.Triggers[0].ThresholdType != "LessThan" {
+		t.Errorf("Expected a critical trigger below 10, but got %+v", throughput)
+	}
+}
+
+// fakeMonitorsAPI is a monitors library with the root folder and the items created through the API
+type fakeMonitorsAPI struct {
+	items   map[string]*monitorsLibraryItem
+	updates []monitor
+}
+
+func (f *fakeMonitorsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/monitors/")
+	switch {
+	case r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.items[id])
+	case r.Method == http.MethodPost:
+		item := monitorsLibraryItem{}
+		json.NewDecoder(r.Body).Decode(&item)
+		item.Id = "item-" + item.Name
+		f.items[item.Id] = &item
+		parent := f.items[r.URL.Query().Get("parentId")]
+		parent.Children = append(parent.Children, item)
+		json.NewEncoder(w).Encode(item)
+	case r.Method == http.MethodPut:
+		update := monitor{}
+		json.NewDecoder(r.Body).Decode(&update)
+		f.updates = append(f.updates, update)
+		json.NewEncoder(w).Encode(f.items[id])
+	}
+}
+
+func TestMonitorsClientUpsert(t *testing.T) {
+	api := &fakeMonitorsAPI{items: map[string]*monitorsLibraryItem{
+		"root": {Id: "root", Name: "Root", Type_: monitorsLibraryFolderType},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := newMonitorsClient(server.URL, "id", "key")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		folder, err := client.projectFolder(ctx, "sockshop")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if folder.Id != "item-sockshop" {
+			t.Errorf("Expected the project folder, but got %+v", folder)
+		}
+
+		id, err := client.upsertMonitor(ctx, folder, monitor{Name: "carts staging: throughput", MonitorType: "Metrics"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "item-carts staging: throughput" {
+			t.Errorf("Expected the id of the monitor, but got %s", id)
+		}
+	}
+
+	// the second run finds the folders and the monitor and updates the monitor
+	if len(api.items) != 4 || len(api.updates) != 1 {
+		t.Fatalf("Expected 2 folders and 1 monitor to be created and the monitor to be updated once, but got %d items and %d updates", len(api.items), len(api.updates))
+	}
+	if created := api.items["item-carts staging: throughput"]; created.Type_ != monitorsLibraryMonitorType {
+		t.Errorf("Expected the monitor to be created as %s, but got %s", monitorsLibraryMonitorType, created.Type_)
+	}
+	if update := api.updates[0]; update.Type_ != monitorsLibraryMonitorUpdateType || update.MonitorType != "Metrics" {
+		t.Errorf("Expected the monitor to be updated with a %s, but got %+v", monitorsLibraryMonitorUpdateType, update)
+	}
+}
+
+func TestBuildDashboard(t *testing.T) {
+	stages := []stageMonitoringConfig{
+		{
+			stage: "staging",
+			slo: &keptn.ServiceLevelObjectives{Objectives: []*keptn.SLO{
+				{
+					SLI:         "response_time_p95",
+					DisplayName: "Response time",
+					Pass:        []*keptn.SLOCriteria{{Criteria: []string{"<=800"}}},
+					Warning:     []*keptn.SLOCriteria{{Criteria: []string{"<=1000"}}},
+				},
+				{SLI: "throughput", Pass: []*keptn.SLOCriteria{{Criteria: []string{">=10"}}}},
+			}},
+			indicators: map[string]indicatorConfig{
+				"response_time_p95": {Type: indicatorTypeMetrics, Query: "metric=latency service=$SERVICE namespace=$PROJECT-$STAGE | quantize to 1m using max | max"},
+				"error_count":       {Type: indicatorTypeLogs, Query: `_sourceCategory=$STAGE/$SERVICE error | count`},
+			},
+		},
+		{stage: "production"},
+	}
+
+	d, skipped := buildDashboard("sockshop", "carts", stages, 15*time.Minute)
+
+	if len(skipped) != 0 {
+		t.Errorf("Expected no skipped panels, but got %v", skipped)
+	}
+	if d.Title != "Keptn sockshop: carts" {
+		t.Errorf("Unexpected dashboard title %s", d.Title)
+	}
+	// error_count and response_time_p95 from sli.yaml and throughput from the default indicators
+	if len(d.Panels) != 3 || len(d.Layout.LayoutStructures) != 3 {
+		t.Fatalf("Expected 3 panels, but got %+v", d.Panels)
+	}
+
+	logs := d.Panels[0]
+	if logs.Title != "error_count" || logs.Queries[0].QueryType != "Logs" || logs.Queries[0].QueryString != "_sourceCategory={{stage}}/{{service}} error | count" {
+		t.Errorf("Expected a log search panel using the variables, but got %+v", logs)
+	}
+
+	latency := d.Panels[1]
+	if latency.Title != "Response time" || latency.Queries[0].QueryString != "metric=latency service={{service}} namespace=sockshop-{{stage}} | quantize to 1m using max | max" {
+		t.Errorf("Expected a metrics panel using the variables, but got %+v", latency)
+	}
+	settings := struct {
+		Thresholds []panelThreshold `json:"thresholds"`
+	}{}
+	if err := json.Unmarshal([]byte(latency.VisualSettings), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.Thresholds) != 3 || *settings.Thresholds[0].To != 800 || *settings.Thresholds[1].From != 800 || *settings.Thresholds[2].From != 1000 {
+		t.Errorf("Expected pass, warning and fail thresholds at 800 and 1000, but got %s", latency.VisualSettings)
+	}
+
+	throughput := d.Panels[2]
+	if throughput.Title != "throughput" || len(throughput.Queries) != 1 {
+		t.Errorf("Expected the default throughput panel, but got %+v", throughput)
+	}
+
+	if d.Variables[0].SourceDefinition.Values != "staging,production" || d.Variables[0].DefaultValue != "staging" || d.Variables[1].DefaultValue != "carts" {
+		t.Errorf("Expected the stage and service variables, but got %+v", d.Variables)
+	}
+}
+
+func TestDashboardsClientUpsert(t *testing.T) {
+	dashboards := map[string]dashboard{"other": {Id: "other", Title: "Other"}}
+	updates := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			list := dashboardList{}
+			for _, d := range dashboards {
+				list.Dashboards = append(list.Dashboards, d)
+			}
+			json.NewEncoder(w).Encode(list)
+		case http.MethodPost:
+			d := dashboard{}
+			json.NewDecoder(r.Body).Decode(&d)
+			d.Id = "dashboard-1"
+			dashboards[d.Id] = d
+			json.NewEncoder(w).Encode(d)
+		case http.MethodPut:
+			updates++
+			json.NewEncoder(w).Encode(dashboards[strings.TrimPrefix(r.URL.Path, "/v2/dashboards/")])
+		}
+	}))
+	defer server.Close()
+
+	client := newDashboardsClient(server.URL, "id", "key")
+	for i := 0; i < 2; i++ {
+		id, err := client.upsertDashboard(context.Background(), dashboard{Title: "Keptn sockshop: carts"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "dashboard-1" {
+			t.Errorf("Expected the id of the dashboard, but got %s", id)
+		}
+	}
+
+	// the second run finds the dashboard by its title and updates it
+	if len(dashboards) != 2 || updates != 1 {
+		t.Errorf("Expected 1 dashboard to be created and updated once, but got %d dashboards and %d updates", len(dashboards), updates)
+	}
+}
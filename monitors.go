@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	monitorsLibraryFolderType  = "MonitorsLibraryFolder"
+	monitorsLibraryMonitorType = "MonitorsLibraryMonitor"
+	// monitorsLibraryMonitorUpdateType is the type of the body to update a monitor
+	monitorsLibraryMonitorUpdateType = "MonitorsLibraryMonitorUpdate"
+	mutingScheduleType               = "MutingSchedulesLibraryMutingSchedule"
+
+	// keptnMonitorsFolder is the folder in the root of the monitors library with a folder per project
+	keptnMonitorsFolder = "Keptn"
+)
+
+// monitorsClient creates and updates monitors using the Sumo Logic Monitors API.
+// The SDK's MonitorsLibraryBase has no queries and triggers, that's why plain HTTP requests are used.
+// Check https://api.sumologic.com/docs/#tag/monitorManagement for more info
+type monitorsClient struct {
+	sumoAPIClient
+}
+
+// monitorsLibraryItem is a folder or a monitor in the monitors library
+type monitorsLibraryItem struct {
+	Id       string                `json:"id,omitempty"`
+	Name     string                `json:"name"`
+	Type_    string                `json:"type"`
+	Version  int64                 `json:"version,omitempty"`
+	Children []monitorsLibraryItem `json:"children,omitempty"`
+}
+
+// monitor is a metrics monitor in the monitors library
+type monitor struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Type_       string           `json:"type"`
+	Version     int64            `json:"version,omitempty"`
+	MonitorType string           `json:"monitorType"`
+	Queries     []monitorQuery   `json:"queries"`
+	Triggers    []monitorTrigger `json:"triggers"`
+	IsDisabled  bool             `json:"isDisabled"`
+}
+
+type monitorQuery struct {
+	RowId string `json:"rowId"`
+	Query string `json:"query"`
+}
+
+type monitorTrigger struct {
+	DetectionMethod string  `json:"detectionMethod"`
+	TriggerType     string  `json:"triggerType"`
+	Threshold       float64 `json:"threshold"`
+	ThresholdType   string  `json:"thresholdType"`
+	TimeRange       string  `json:"timeRange"`
+	OccurrenceType  string  `json:"occurrenceType"`
+	TriggerSource   string  `json:"triggerSource"`
+}
+
+func newMonitorsClient(basePath, accessId, accessKey string) *monitorsClient {
+	return &monitorsClient{
+		sumoAPIClient{
+			basePath:   basePath,
+			accessId:   accessId,
+			accessKey:  accessKey,
+			httpClient: &http.Client{Transport: newLimitedTransport(http.DefaultTransport, sumoAPILimiter)},
+		},
+	}
+}
+
+// projectFolder returns the folder `Keptn/<project>` in the monitors library (and creates it if it doesn't exist)
+func (c *monitorsClient) projectFolder(ctx context.Context, project string) (monitorsLibraryItem, error) {
+	root := monitorsLibraryItem{}
+	if err := c.do(ctx, http.MethodGet, "/v1/monitors/root", nil, &root); err != nil {
+		return root, err
+	}
+
+	keptnFolder, err := c.ensureFolder(ctx, root, keptnMonitorsFolder, "Monitors created by Keptn")
+	if err != nil {
+		return keptnFolder, err
+	}
+	return c.ensureFolder(ctx, keptnFolder, project, fmt.Sprintf("Monitors created by Keptn for the project %s", project))
+}
+
+// ensureFolder returns the child folder with the name (with its children) and creates it if it doesn't exist
+func (c *monitorsClient) ensureFolder(ctx context.Context, parent monitorsLibraryItem, name, description string) (monitorsLibraryItem, error) {
+	folder := monitorsLibraryItem{}
+	if child, ok := findChild(parent, name, monitorsLibraryFolderType); ok {
+		err := c.do(ctx, http.MethodGet, "/v1/monitors/"+url.PathEscape(child.Id), nil, &folder)
+		return folder, err
+	}
+
+	body := map[string]string{"name": name, "description": description, "type": monitorsLibraryFolderType}
+	err := c.do(ctx, http.MethodPost, "/v1/monitors?parentId="+url.QueryEscape(parent.Id), body, &folder)
+	return folder, err
+}
+
+// upsertMonitor updates the monitor with the same name in the folder or creates it and returns its id
+func (c *monitorsClient) upsertMonitor(ctx context.Context, folder monitorsLibraryItem, m monitor) (string, error) {
+	m.Type_ = monitorsLibraryMonitorType
+	saved := monitorsLibraryItem{}
+
+	if existing, ok := findChild(folder, m.Name, monitorsLibraryMonitorType); ok {
+		m.Type_ = monitorsLibraryMonitorUpdateType
+		m.Version = existing.Version
+		err := c.do(ctx, http.MethodPut, "/v1/monitors/"+url.PathEscape(existing.Id), m, &saved)
+		return existing.Id, err
+	}
+
+	err := c.do(ctx, http.MethodPost, "/v1/monitors?parentId="+url.QueryEscape(folder.Id), m, &saved)
+	return saved.Id, err
+}
+
+func findChild(parent monitorsLibraryItem, name, itemType string) (monitorsLibraryItem, bool) {
+	for _, child := range parent.Children {
+		if child.Name == name && child.Type_ == itemType {
+			return child, true
+		}
+	}
+	return monitorsLibraryItem{}, false
+}
+
+// mutingSchedule mutes monitors or all the monitors in folders for a period of time
+// Check https://api.sumologic.com/docs/#tag/mutingSchedulesLibraryManagement for more info
+type mutingSchedule struct {
+	Id          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Type_       string                 `json:"type"`
+	Monitor     mutingScheduleScope    `json:"monitor"`
+	Schedule    mutingScheduleDuration `json:"schedule"`
+}
+
+type mutingScheduleScope struct {
+	// Ids are the ids of the monitors and folders which are muted
+	Ids []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+type mutingScheduleDuration struct {
+	Timezone  string `json:"timezone"`
+	StartDate string `json:"startDate"`
+	StartTime string `json:"startTime"`
+	// Duration is the length of the muting in minutes
+	Duration int64 `json:"duration"`
+}
+
+// monitorReadOnlyFields are the fields of a monitor returned by the API which are not part of the update model
+var monitorReadOnlyFields = []string{
+	"id", "parentId", "contentType", "createdAt", "createdBy", "modifiedAt", "modifiedBy",
+	"isLocked", "isSystem", "isMutable", "status", "warnings", "permissions", "children",
+}
+
+// setMonitorDisabled disables or enables the monitor and returns whether it was disabled before.
+// The monitor is updated with the fields returned by the API (without the read-only ones) so that no other field is changed.
+func (c *monitorsClient) setMonitorDisabled(ctx context.Context, id string, disabled bool) (bool, error) {
+	m := map[string]interface{}{}
+	if err := c.do(ctx, http.MethodGet, "/v1/monitors/"+url.PathEscape(id), nil, &m); err != nil {
+		return false, err
+	}
+	if m["type"] != monitorsLibraryMonitorType {
+		return false, fmt.Errorf("%s is a %v and not a monitor", id, m["type"])
+	}
+
+	wasDisabled, _ := m["isDisabled"].(bool)
+	for _, field := range monitorReadOnlyFields {
+		delete(m, field)
+	}
+	m["type"] = monitorsLibraryMonitorUpdateType
+	m["isDisabled"] = disabled
+	return wasDisabled, c.do(ctx, http.MethodPut, "/v1/monitors/"+url.PathEscape(id), m, nil)
+}
+
+// muteMonitors creates a muting schedule in the root folder of the muting schedules library
+// which mutes the monitors or folders from start for the duration and returns its id
+func (c *monitorsClient) muteMonitors(ctx context.Context, name, description string, ids []string, start time.Time, duration time.Duration) (string, error) {
+	root := monitorsLibraryItem{}
+	if err := c.do(ctx, http.MethodGet, "/v1/mutingSchedules/root", nil, &root); err != nil {
+		return "", err
+	}
+
+	start = start.UTC()
+	schedule := mutingSchedule{
+		Name:        name,
+		Description: description,
+		Type_:       mutingScheduleType,
+		Monitor:     mutingScheduleScope{Ids: ids},
+		Schedule: mutingScheduleDuration{
+			Timezone:  "UTC",
+			StartDate: start.Format("2006-01-02"),
+			StartTime: start.Format("15:04"),
+			Duration:  int64(math.Ceil(duration.Minutes())),
+		},
+	}
+
+	saved := mutingSchedule{}
+	err := c.do(ctx, http.MethodPost, "/v1/mutingSchedules?parentId="+url.QueryEscape(root.Id), schedule, &saved)
+	return saved.Id, err
+}
+
+// unmuteMonitors deletes the muting schedule
+func (c *monitorsClient) unmuteMonitors(ctx context.Context, mutingScheduleId string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/mutingSchedules/"+url.PathEscape(mutingScheduleId), nil, nil)
+}
+
+// resolveAlert resolves the alert
+// Check https://api.sumologic.com/docs/#operation/resolveAlert for more info
+func (c *monitorsClient) resolveAlert(ctx context.Context, alertId string) error {
+	return c.do(ctx, http.MethodPost, "/v1/alerts/"+url.PathEscape(alertId)+"/resolve", nil, nil)
+}
@@ -0,0 +1,138 @@
+package metricsquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind is the kind of a token in a metrics query
+type TokenKind int
+
+const (
+	// Word is a metric name, a dimension key or value, a keyword, a number or a duration
+	Word TokenKind = iota
+	// String is a double-quoted string (the value doesn't include the quotes)
+	String
+	// Pipe separates the selector and the operators
+	Pipe
+	// LParen is `(`
+	LParen
+	// RParen is `)`
+	RParen
+	// Comma is `,`
+	Comma
+	// Comparison is one of `=`, `!=`, `<`, `<=`, `>` or `>=`
+	Comparison
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case Word:
+		return "word"
+	case String:
+		return "string"
+	case Pipe:
+		return "`|`"
+	case LParen:
+		return "`(`"
+	case RParen:
+		return "`)`"
+	case Comma:
+		return "`,`"
+	case Comparison:
+		return "comparison"
+	}
+	return "unknown"
+}
+
+// Token is a single token of a metrics query
+type Token struct {
+	Kind  TokenKind
+	Value string
+	// Pos is the offset of the token in the query (0-based)
+	Pos int
+	// End is the offset right after the token in the query
+	End int
+}
+
+// SyntaxError is an error in a metrics query with the position where it happened
+type SyntaxError struct {
+	// Pos is the offset in the query (0-based)
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos+1, e.Msg)
+}
+
+func newSyntaxError(pos int, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isWordDelimiter returns true for the characters that end a word
+func isWordDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n|(),=!<>\"", c) >= 0
+}
+
+// Tokenize splits a metrics query into tokens
+func Tokenize(query string) ([]Token, error) {
+	tokens := []Token{}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '|':
+			tokens = append(tokens, Token{Kind: Pipe, Value: "|", Pos: i, End: i + 1})
+			i++
+		case c == '(':
+			tokens = append(tokens, Token{Kind: LParen, Value: "(", Pos: i, End: i + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, Token{Kind: RParen, Value: ")", Pos: i, End: i + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, Token{Kind: Comma, Value: ",", Pos: i, End: i + 1})
+			i++
+		case c == '=':
+			tokens = append(tokens, Token{Kind: Comparison, Value: "=", Pos: i, End: i + 1})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			if i+1 < len(query) && query[i+1] == '=' {
+				tokens = append(tokens, Token{Kind: Comparison, Value: query[i : i+2], Pos: i, End: i + 2})
+				i += 2
+				continue
+			}
+			if c == '!' {
+				return nil, newSyntaxError(i, "unexpected `!` (did you mean `!=`?)")
+			}
+			tokens = append(tokens, Token{Kind: Comparison, Value: string(c), Pos: i, End: i + 1})
+			i++
+		case c == '"':
+			start := i
+			value := strings.Builder{}
+			i++
+			for ; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' && i+1 < len(query) {
+					i++
+				}
+				value.WriteByte(query[i])
+			}
+			if i >= len(query) {
+				return nil, newSyntaxError(start, "unterminated string")
+			}
+			i++
+			tokens = append(tokens, Token{Kind: String, Value: value.String(), Pos: start, End: i})
+		default:
+			start := i
+			for i < len(query) && !isWordDelimiter(query[i]) {
+				i++
+			}
+			tokens = append(tokens, Token{Kind: Word, Value: query[start:i], Pos: start, End: i})
+		}
+	}
+
+	return tokens, nil
+}
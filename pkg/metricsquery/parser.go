@@ -0,0 +1,528 @@
+package metricsquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a parsed Sumo Logic metrics query: a selector followed by operators, e.g.,
+//
+//	metric=cpu_usage service=carts | quantize to 1m using avg | sum by pod
+//
+// Check https://help.sumologic.com/Metrics/Metric-Queries-and-Alerts/07Metrics_Operators for the operators
+type Query struct {
+	Selector  Selector
+	Operators []Operator
+}
+
+// Selector selects the time series, e.g., `metric=cpu_usage service=carts`
+type Selector struct {
+	Tokens []Token
+	// Text is the selector as written in the query
+	Text string
+	Pos  int
+}
+
+// Operator is a single operator after a `|`, e.g., `quantize to 1m using avg`
+type Operator struct {
+	// Name is the lower case name of the operator
+	Name string
+	// Args are the tokens after the name of the operator
+	Args []Token
+	// Text is the operator as written in the query
+	Text string
+	Pos  int
+	End  int
+}
+
+// Parse parses a metrics query
+func Parse(query string) (*Query, error) {
+	tokens, err := Tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// split the tokens into the selector and the operators
+	segments := [][]Token{{}}
+	pipes := []Token{}
+	for _, token := range tokens {
+		if token.Kind == Pipe {
+			segments = append(segments, []Token{})
+			pipes = append(pipes, token)
+			continue
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], token)
+	}
+
+	selectorTokens := segments[0]
+	if len(selectorTokens) == 0 {
+		return nil, newSyntaxError(0, "query should start with a metric selector (e.g., `metric=cpu_usage`)")
+	}
+	if err := validateSelector(selectorTokens); err != nil {
+		return nil, err
+	}
+
+	q := &Query{
+		Selector: Selector{
+			Tokens: selectorTokens,
+			Text:   textOf(query, selectorTokens),
+			Pos:    selectorTokens[0].Pos,
+		},
+	}
+
+	for i, segment := range segments[1:] {
+		if len(segment) == 0 {
+			return nil, newSyntaxError(pipes[i].Pos, "expected an operator after `|`")
+		}
+		if segment[0].Kind != Word {
+			return nil, newSyntaxError(segment[0].Pos, "expected an operator name after `|` but got %s", segment[0].Kind)
+		}
+		if err := validateParens(segment); err != nil {
+			return nil, err
+		}
+
+		q.Operators = append(q.Operators, Operator{
+			Name: strings.ToLower(segment[0].Value),
+			Args: segment[1:],
+			Text: textOf(query, segment),
+			Pos:  segment[0].Pos,
+			End:  segment[len(segment)-1].End,
+		})
+	}
+
+	return q, nil
+}
+
+// String formats the query from the selector and the operators
+func (q *Query) String() string {
+	parts := []string{q.Selector.Text}
+	for _, op := range q.Operators {
+		parts = append(parts, op.Text)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// FindOperators returns the operators with the given name
+func (q *Query) FindOperators(name string) []Operator {
+	found := []Operator{}
+	for _, op := range q.Operators {
+		if op.Name == name {
+			found = append(found, op)
+		}
+	}
+	return found
+}
+
+// RemoveOperators removes the operators with the given name from the query and returns them
+func (q *Query) RemoveOperators(name string) []Operator {
+	removed := []Operator{}
+	kept := []Operator{}
+	for _, op := range q.Operators {
+		if op.Name == name {
+			removed = append(removed, op)
+		} else {
+			kept = append(kept, op)
+		}
+	}
+	q.Operators = kept
+	return removed
+}
+
+// HasKey returns true if the selector compares the given key (case insensitive), e.g., `pod` in `metric=cpu pod=carts-1`
+func (s Selector) HasKey(key string) bool {
+	for i, token := range s.Tokens {
+		if token.Kind == Comparison && i > 0 && strings.EqualFold(s.Tokens[i-1].Value, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRowExpression returns true if the selector refers to other rows of the request,
+// e.g., `#A / #B`, `(#A + #B) / 2` or `100 * #A/#B`
+func (s Selector) IsRowExpression() bool {
+	for i, token := range s.Tokens {
+		// a value like `tag=#A` isn't a reference
+		if token.Kind != Word || (i > 0 && s.Tokens[i-1].Kind == Comparison) {
+			continue
+		}
+		if hasRowReference(token.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRowReference returns true if the word has a `#` followed by a row id (A to Z)
+// at its start or after an arithmetic operator, e.g., `#A` or `2*#B`
+func hasRowReference(word string) bool {
+	for i := 0; i+1 < len(word); i++ {
+		if word[i] == '#' && word[i+1] >= 'A' && word[i+1] <= 'Z' && (i == 0 || strings.IndexByte("+-*/", word[i-1]) >= 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFilter appends `key=value` to the selector (the value is quoted if needed).
+// A selector with a top-level `or` is put in parens first, e.g., `(metric=a or metric=b) key=value`,
+// so that the filter applies to all the alternatives and not just the last one.
+func (q *Query) AddFilter(key, value string) {
+	text := q.Selector.Text
+	if q.Selector.hasTopLevelOr() {
+		text = "(" + text + ")"
+	}
+	text += " " + key + "=" + quoteValue(value)
+
+	tokens, err := Tokenize(text)
+	if err != nil {
+		return
+	}
+	// the tokens are positioned relative to the start of the selector in the query
+	for i := range tokens {
+		tokens[i].Pos += q.Selector.Pos
+		tokens[i].End += q.Selector.Pos
+	}
+	q.Selector.Text = text
+	q.Selector.Tokens = tokens
+}
+
+// hasTopLevelOr returns true if the selector has an `or` outside of parens
+func (s Selector) hasTopLevelOr() bool {
+	depth := 0
+	for _, token := range s.Tokens {
+		switch {
+		case token.Kind == LParen:
+			depth++
+		case token.Kind == RParen:
+			depth--
+		case token.Kind == Word && depth == 0 && strings.EqualFold(token.Value, "or"):
+			return true
+		}
+	}
+	return false
+}
+
+// quoteValue quotes a selector value unless it is a single word
+func quoteValue(value string) string {
+	needsQuotes := value == ""
+	for i := 0; i < len(value); i++ {
+		if isWordDelimiter(value[i]) || value[i] == '\\' {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func textOf(query string, tokens []Token) string {
+	return query[tokens[0].Pos:tokens[len(tokens)-1].End]
+}
+
+// validateSelector makes sure the parens are balanced and that
+// every comparison has a key on the left and a value on the right
+func validateSelector(tokens []Token) error {
+	if err := validateParens(tokens); err != nil {
+		return err
+	}
+
+	for i, token := range tokens {
+		if token.Kind != Comparison {
+			continue
+		}
+		if i == 0 || (tokens[i-1].Kind != Word && tokens[i-1].Kind != String) {
+			return newSyntaxError(token.Pos, "expected a key before `%s`", token.Value)
+		}
+		if i == len(tokens)-1 || (tokens[i+1].Kind != Word && tokens[i+1].Kind != String) {
+			return newSyntaxError(token.Pos, "expected a value after `%s`", token.Value)
+		}
+	}
+	return nil
+}
+
+func validateParens(tokens []Token) error {
+	open := []Token{}
+	for _, token := range tokens {
+		switch token.Kind {
+		case LParen:
+			open = append(open, token)
+		case RParen:
+			if len(open) == 0 {
+				return newSyntaxError(token.Pos, "unexpected `)`")
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return newSyntaxError(open[len(open)-1].Pos, "unclosed `(`")
+	}
+	return nil
+}
+
+// Quantize are the arguments of the quantize operator
+// Check https://help.sumologic.com/Metrics/Metric-Queries-and-Alerts/07Metrics_Operators/quantize
+type Quantize struct {
+	Interval time.Duration
+	// Rollup is one of avg, min, max, sum, count or none (empty if not specified)
+	Rollup   string
+	DropLast bool
+}
+
+var rollups = map[string]bool{"avg": true, "min": true, "max": true, "sum": true, "count": true, "none": true}
+
+// ParseQuantize parses the arguments of `quantize [to] INTERVAL [using ROLLUP] [drop last]`
+func ParseQuantize(op Operator) (Quantize, error) {
+	args := op.Args
+	quantize := Quantize{}
+
+	next := func() (Token, bool) {
+		if len(args) == 0 {
+			return Token{}, false
+		}
+		token := args[0]
+		args = args[1:]
+		return token, true
+	}
+
+	token, ok := next()
+	if ok && token.Kind == Word && strings.ToLower(token.Value) == "to" {
+		token, ok = next()
+	}
+	if !ok {
+		return quantize, newSyntaxError(op.End, "expected an interval after `quantize` (e.g., `quantize to 1m`)")
+	}
+	interval, err := ParseDuration(token.Value)
+	if err != nil || token.Kind != Word || interval <= 0 {
+		return quantize, newSyntaxError(token.Pos, "invalid quantize interval `%s` (e.g., `30s`, `5m` or `1h`)", token.Value)
+	}
+	quantize.Interval = interval
+
+	for {
+		token, ok = next()
+		if !ok {
+			return quantize, nil
+		}
+
+		switch strings.ToLower(token.Value) {
+		case "using":
+			rollup, ok := next()
+			if !ok {
+				return quantize, newSyntaxError(token.End, "expected a rollup after `using` (one of avg, min, max, sum, count or none)")
+			}
+			if !rollups[strings.ToLower(rollup.Value)] {
+				return quantize, newSyntaxError(rollup.Pos, "invalid rollup `%s` (should be one of avg, min, max, sum, count or none)", rollup.Value)
+			}
+			quantize.Rollup = strings.ToLower(rollup.Value)
+		case "drop":
+			last, ok := next()
+			if !ok || strings.ToLower(last.Value) != "last" {
+				return quantize, newSyntaxError(token.Pos, "expected `drop last`")
+			}
+			quantize.DropLast = true
+		default:
+			return quantize, newSyntaxError(token.Pos, "unexpected `%s` in quantize (expected `using ROLLUP` or `drop last`)", token.Value)
+		}
+	}
+}
+
+// ParseTimeshift parses the argument of `timeshift [+|-]TIME_INTERVAL`, e.g., `timeshift 1d`
+// Check https://help.sumologic.com/Metrics/Metric-Queries-and-Alerts/07Metrics_Operators/timeshift
+func ParseTimeshift(op Operator) (time.Duration, error) {
+	if len(op.Args) == 0 {
+		return 0, newSyntaxError(op.End, "expected an interval after `timeshift` (e.g., `timeshift 1d`)")
+	}
+	if len(op.Args) > 1 {
+		return 0, newSyntaxError(op.Args[1].Pos, "unexpected `%s` in timeshift", op.Args[1].Value)
+	}
+
+	token := op.Args[0]
+	shift, err := ParseDuration(token.Value)
+	if err != nil || token.Kind != Word {
+		return 0, newSyntaxError(token.Pos, "invalid timeshift interval `%s` (e.g., `1h`, `1d` or `1w`)", token.Value)
+	}
+	return shift, nil
+}
+
+// FillMissing strategies
+const (
+	FillConstant      = "constant"
+	FillLast          = "last"
+	FillNext          = "next"
+	FillInterpolation = "interpolation"
+)
+
+// FillMissing are the arguments of the fillmissing operator
+// Check https://help.sumologic.com/Metrics/Metric-Queries-and-Alerts/07Metrics_Operators/fillmissing
+type FillMissing struct {
+	// Strategy is one of FillConstant, FillLast, FillNext or FillInterpolation
+	Strategy string
+	// Value is the value used with FillConstant
+	Value float64
+}
+
+// ParseFillMissing parses the arguments of `fillmissing using {zero | NUMBER | last | next | interpolation}`
+func ParseFillMissing(op Operator) (FillMissing, error) {
+	args := op.Args
+	if len(args) > 0 && strings.ToLower(args[0].Value) == "using" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return FillMissing{}, newSyntaxError(op.End, "expected a strategy after `fillmissing using` (one of zero, a number, last, next or interpolation)")
+	}
+	if len(args) > 1 {
+		return FillMissing{}, newSyntaxError(args[1].Pos, "unexpected `%s` in fillmissing", args[1].Value)
+	}
+
+	token := args[0]
+	switch strings.ToLower(token.Value) {
+	case "zero":
+		return FillMissing{Strategy: FillConstant}, nil
+	case "last":
+		return FillMissing{Strategy: FillLast}, nil
+	case "next":
+		return FillMissing{Strategy: FillNext}, nil
+	case "interpolation", "interpolate":
+		return FillMissing{Strategy: FillInterpolation}, nil
+	}
+
+	value, err := strconv.ParseFloat(token.Value, 64)
+	if err != nil || token.Kind != Word {
+		return FillMissing{}, newSyntaxError(token.Pos, "invalid fillmissing strategy `%s` (should be one of zero, a number, last, next or interpolation)", token.Value)
+	}
+	return FillMissing{Strategy: FillConstant, Value: value}, nil
+}
+
+// Outlier directions
+const (
+	OutlierBoth = "+-"
+	OutlierUp   = "+"
+	OutlierDown = "-"
+)
+
+// Outlier are the arguments of the outlier operator
+// Check https://help.sumologic.com/Metrics/Metric-Queries-and-Alerts/07Metrics_Operators/outlier
+type Outlier struct {
+	// Window is the number of preceding points used for the moving average and standard deviation
+	Window int
+	// Threshold is the number of standard deviations a point has to be away from the moving average
+	Threshold float64
+	// Consecutive is the number of consecutive points that have to be away from the moving average
+	Consecutive int
+	// Direction is one of OutlierBoth, OutlierUp or OutlierDown
+	Direction string
+}
+
+// ParseOutlier parses the arguments of `outlier [window=N] [threshold=T] [consecutive=C] [direction=+-]`
+// using the defaults of Sumo Logic (window=5 threshold=3 consecutive=1 direction=+-)
+func ParseOutlier(op Operator) (Outlier, error) {
+	outlier := Outlier{Window: 5, Threshold: 3, Consecutive: 1, Direction: OutlierBoth}
+
+	args := op.Args
+	for len(args) > 0 {
+		key := args[0]
+		if len(args) < 3 || key.Kind != Word || args[1].Kind != Comparison || args[1].Value != "=" {
+			return outlier, newSyntaxError(key.Pos, "expected `window=N`, `threshold=T`, `consecutive=C` or `direction=+-` in outlier")
+		}
+		value := args[2]
+		args = args[3:]
+
+		switch strings.ToLower(key.Value) {
+		case "window":
+			n, err := strconv.Atoi(value.Value)
+			if err != nil || n < 1 {
+				return outlier, newSyntaxError(value.Pos, "invalid outlier window `%s` (should be a positive number of points)", value.Value)
+			}
+			outlier.Window = n
+		case "threshold":
+			t, err := strconv.ParseFloat(value.Value, 64)
+			if err != nil || t < 0 {
+				return outlier, newSyntaxError(value.Pos, "invalid outlier threshold `%s` (should be a number of standard deviations)", value.Value)
+			}
+			outlier.Threshold = t
+		case "consecutive":
+			n, err := strconv.Atoi(value.Value)
+			if err != nil || n < 1 {
+				return outlier, newSyntaxError(value.Pos, "invalid outlier consecutive `%s` (should be a positive number of points)", value.Value)
+			}
+			outlier.Consecutive = n
+		case "direction":
+			switch value.Value {
+			case OutlierBoth, "-+":
+				outlier.Direction = OutlierBoth
+			case OutlierUp, OutlierDown:
+				outlier.Direction = value.Value
+			default:
+				return outlier, newSyntaxError(value.Pos, "invalid outlier direction `%s` (should be one of +-, + or -)", value.Value)
+			}
+		default:
+			return outlier, newSyntaxError(key.Pos, "unexpected `%s` in outlier (expected window, threshold, consecutive or direction)", key.Value)
+		}
+	}
+
+	return outlier, nil
+}
+
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	// ms has to come before m and s
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+}
+
+// ParseDuration parses a Sumo Logic duration like `30s`, `5m`, `1h30m`, `1d` or `-1w`
+func ParseDuration(s string) (time.Duration, error) {
+	original := s
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration `%s`", original)
+	}
+
+	var total time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration `%s`", original)
+		}
+		n, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration `%s`", original)
+		}
+		s = s[i:]
+
+		found := false
+		for _, u := range durationUnits {
+			if strings.HasPrefix(s, u.suffix) {
+				total += time.Duration(n) * u.unit
+				s = s[len(u.suffix):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid duration `%s` (units are ms, s, m, h, d and w)", original)
+		}
+	}
+
+	return sign * total, nil
+}
@@ -0,0 +1,222 @@
+package metricsquery
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`metric=container_quantize_total service="carts" | quantize to 5m using avg | sum by (pod, namespace)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Selector.Text != `metric=container_quantize_total service="carts"` {
+		t.Errorf("Unexpected selector: %s", q.Selector.Text)
+	}
+	if len(q.Operators) != 2 || q.Operators[0].Name != "quantize" || q.Operators[1].Name != "sum" {
+		t.Fatalf("Expected the operators quantize and sum, but got %+v", q.Operators)
+	}
+	if q.Operators[1].Text != "sum by (pod, namespace)" {
+		t.Errorf("Unexpected operator text: %s", q.Operators[1].Text)
+	}
+
+	q.RemoveOperators("quantize")
+	if got := q.String(); got != `metric=container_quantize_total service="carts" | sum by (pod, namespace)` {
+		t.Errorf("Unexpected formatted query: %s", got)
+	}
+}
+
+func TestSelectorIsRowExpression(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"#A / #B", true},
+		{"(#A + #B) / 2", true},
+		{"100 * #A / #B", true},
+		{"100*#A/#B", true},
+		{"metric=cpu | sum", false},
+		{"metric=cpu tag=#A", false},
+		{`metric=cpu pod="#A"`, false},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := q.Selector.IsRowExpression(); got != tt.want {
+			t.Errorf("IsRowExpression() of %s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{"| sum", 0},
+		{"metric=cpu | ", 11},
+		{"metric=cpu | | sum", 11},
+		{`metric="cpu`, 7},
+		{"metric=cpu | sum by (pod", 20},
+		{"metric= | sum", 6},
+		{"metric=cpu service!carts", 18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			syntaxErr := &SyntaxError{}
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Expected a syntax error, but got %v", err)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Errorf("Expected the error at position %d, but got %d (%v)", tt.pos, syntaxErr.Pos, err)
+			}
+		})
+	}
+}
+
+func TestParseQuantize(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Quantize
+	}{
+		{"metric=cpu | quantize to 5m using avg", Quantize{Interval: 5 * time.Minute, Rollup: "avg"}},
+		{"metric=cpu | quantize 30s", Quantize{Interval: 30 * time.Second}},
+		{"metric=cpu | quantize to 1h using SUM drop last", Quantize{Interval: time.Hour, Rollup: "sum", DropLast: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseQuantize(q.Operators[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQuantize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"metric=cpu | quantize", "metric=cpu | quantize to 5x", "metric=cpu | quantize to 5m using mean"} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseQuantize(q.Operators[0]); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30s":   30 * time.Second,
+		"5m":    5 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"-1w":   -7 * 24 * time.Hour,
+		"500ms": 500 * time.Millisecond,
+	}
+
+	for s, want := range tests {
+		got, err := ParseDuration(s)
+		if err != nil {
+			t.Errorf("ParseDuration(%s) returned %v", s, err)
+		}
+		if got != want {
+			t.Errorf("ParseDuration(%s) = %v, want %v", s, got, want)
+		}
+	}
+
+	for _, s := range []string{"", "m", "5", "5y", "-"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("Expected an error for %s", s)
+		}
+	}
+}
+
+func TestParseFillMissing(t *testing.T) {
+	tests := []struct {
+		query string
+		want  FillMissing
+	}{
+		{"metric=cpu | fillmissing using zero", FillMissing{Strategy: FillConstant}},
+		{"metric=cpu | fillmissing using -1.5", FillMissing{Strategy: FillConstant, Value: -1.5}},
+		{"metric=cpu | fillmissing using LAST", FillMissing{Strategy: FillLast}},
+		{"metric=cpu | fillmissing next", FillMissing{Strategy: FillNext}},
+		{"metric=cpu | fillmissing using interpolation", FillMissing{Strategy: FillInterpolation}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFillMissing(q.Operators[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFillMissing() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"metric=cpu | fillmissing", "metric=cpu | fillmissing using", "metric=cpu | fillmissing using previous", "metric=cpu | fillmissing using zero last"} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseFillMissing(q.Operators[0]); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
+
+func TestParseOutlier(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Outlier
+	}{
+		{"metric=cpu | outlier", Outlier{Window: 5, Threshold: 3, Consecutive: 1, Direction: OutlierBoth}},
+		{"metric=cpu | outlier window=10 threshold=2.5 consecutive=2 direction=+", Outlier{Window: 10, Threshold: 2.5, Consecutive: 2, Direction: OutlierUp}},
+		{"metric=cpu | outlier direction=-", Outlier{Window: 5, Threshold: 3, Consecutive: 1, Direction: OutlierDown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseOutlier(q.Operators[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseOutlier() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"metric=cpu | outlier window=0", "metric=cpu | outlier threshold", "metric=cpu | outlier direction=up", "metric=cpu | outlier size=5"} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseOutlier(q.Operators[0]); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+// labelPlaceholderPrefix is the prefix of the placeholders for the labels of the event, e.g., `$LABEL.buildId`
+const labelPlaceholderPrefix = "LABEL."
+
+// placeholderPattern matches `$$`, `${NAME}`, `${NAME:default}`, `$LABEL.name` and `$NAME`
+var placeholderPattern = regexp.MustCompile(`\$(?:(\$)|\{([^}:]+)(?::([^}]*))?\}|(LABEL\.[A-Za-z0-9_\-]+)|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// quoteEscaper escapes the values so they can be used inside double-quoted strings in the queries
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// newPlaceholderValues returns the values of the placeholders for the get-sli.triggered event
+func newPlaceholderValues(data *keptnv2.GetSLITriggeredEventData, start, end time.Time) map[string]string {
+	values := map[string]string{}
+
+	// the custom filters come first so that the built-in placeholders can't be overridden by them
+	for _, filter := range validCustomFilters(data.GetSLI.CustomFilters) {
+		values[filter.Key] = filter.Value
+		values[strings.ToUpper(filter.Key)] = filter.Value
+	}
+
+	for name, value := range data.Labels {
+		values[labelPlaceholderPrefix+name] = value
+	}
+
+	values["PROJECT"] = data.Project
+	values["STAGE"] = data.Stage
+	values["SERVICE"] = data.Service
+	values["project"] = data.Project
+	values["stage"] = data.Stage
+	values["service"] = data.Service
+	values["DEPLOYMENT"] = data.Deployment
+
+	values["DURATION"] = fmt.Sprintf("%ds", getDurationInSeconds(start, end))
+	values["DURATION_SECONDS"] = strconv.FormatInt(getDurationInSeconds(start, end), 10)
+	values["DURATION_MINUTES"] = strconv.FormatInt(int64(math.Ceil(end.Sub(start).Minutes())), 10)
+
+	for name, t := range map[string]time.Time{"START": start, "END": end} {
+		values[name] = t.UTC().Format(time.RFC3339)
+		values[name+"_UNIX"] = strconv.FormatInt(t.Unix(), 10)
+		values[name+"_UNIX_MS"] = strconv.FormatInt(t.UnixMilli(), 10)
+		values[name+"_DATE"] = t.UTC().Format("2006-01-02")
+	}
+
+	return values
+}
+
+// expandPlaceholders replaces the placeholders in the query with their values (quotes in the values are escaped).
+// A placeholder without a value (or with an empty value) falls back to its default, e.g., `${DEPLOYMENT:canary}`.
+// All the placeholders which can't be resolved are listed in the error.
+func expandPlaceholders(query string, values map[string]string) (string, error) {
+	unresolved := []string{}
+
+	expanded := placeholderPattern.ReplaceAllStringFunc(query, func(placeholder string) string {
+		groups := placeholderPattern.FindStringSubmatch(placeholder)
+		if groups[1] != "" {
+			return "$"
+		}
+
+		name := groups[2] + groups[4] + groups[5]
+		value, ok := values[name]
+		if ok && value != "" {
+			return quoteEscaper.Replace(value)
+		}
+
+		// only the `${NAME:default}` form has a default (which might be empty)
+		if strings.Contains(placeholder, ":") {
+			return quoteEscaper.Replace(groups[3])
+		}
+
+		unresolved = append(unresolved, placeholder)
+		return placeholder
+	})
+
+	if len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return "", fmt.Errorf("unresolved placeholders %s (use `${NAME:default}` for values that might be missing)", strings.Join(uniqueStrings(unresolved), ", "))
+	}
+	return expanded, nil
+}
+
+// uniqueStrings removes the duplicates from a sorted list
+func uniqueStrings(sorted []string) []string {
+	unique := []string{}
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+func TestReplaceQueryParameters(t *testing.T) {
+	start := time.Date(2021, 1, 15, 15, 4, 45, 0, time.UTC)
+	end := start.Add(5 * time.Minute)
+	data := &keptnv2.GetSLITriggeredEventData{
+		EventData: keptnv2.EventData{
+			Project: "sockshop",
+			Stage:   "staging",
+			Service: "carts",
+			Labels:  map[string]string{"buildId": "build-17", "owner": `team "a"`},
+		},
+		GetSLI: keptnv2.GetSLI{
+			CustomFilters: []*keptnv2.SLIFilter{
+				{Key: "region", Value: "eu-west-1"},
+				{Key: "region_name", Value: "Ireland"},
+			},
+		},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"service=$SERVICE stage=$stage | quantize to $DURATION", "service=carts stage=staging | quantize to 300s"},
+		{"quantize to ${DURATION_MINUTES}m", "quantize to 5m"},
+		{"from $START_UNIX to $END_UNIX_MS at $START", "from 1610723085 to 1610723385000 at 2021-01-15T15:04:45Z"},
+		{"build=$LABEL.buildId owner=\"${LABEL.owner}\"", `build=build-17 owner="team \"a\""`},
+		{"region=$REGION name=$region_name", "region=eu-west-1 name=Ireland"},
+		{"deployment=${DEPLOYMENT:primary} pod=${LABEL.pod:}", "deployment=primary pod="},
+		{`parse regex "(?<code>\d+)$" | price=$$5`, `parse regex "(?<code>\d+)$" | price=$5`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := replaceQueryParameters(data, tt.query, start, end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, but got %s", tt.want, got)
+			}
+		})
+	}
+
+	_, err := replaceQueryParameters(data, "metric=cpu deployment=$DEPLOYMENT build=$LABEL.missing x=$FOO y=$FOO", start, end)
+	if err == nil {
+		t.Fatal("Expected an error for unresolved placeholders")
+	}
+	if !strings.Contains(err.Error(), "$DEPLOYMENT, $FOO, $LABEL.missing") {
+		t.Errorf("Expected the unresolved placeholders in the error, but got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+const (
+	metricsFormatPrometheus = "prometheus"
+	metricsFormatCarbon2    = "carbon2"
+
+	httpSourcePrometheusContentType = "application/vnd.sumologic.prometheus"
+	httpSourceCarbon2ContentType    = "application/vnd.sumologic.carbon2"
+)
+
+// qualityGateDataPoint is a data point of a quality gate metric
+type qualityGateDataPoint struct {
+	metric     string
+	dimensions map[string]string
+	value      float64
+	timestamp  time.Time
+}
+
+// resultValue turns the result of an evaluation or an indicator into a number which can be charted
+// (pass is 1, warning is 0.5 and fail is 0)
+func resultValue(result string) (float64, bool) {
+	switch keptnv2.ResultType(result) {
+	case keptnv2.ResultPass:
+		return 1, true
+	case keptnv2.ResultWarning:
+		return 0.5, true
+	case keptnv2.ResultFailed:
+		return 0, true
+	}
+	return 0, false
+}
+
+// qualityGateDataPoints turns the evaluation into data points with the dimensions project, stage, service and keptnContext:
+// - keptn_evaluation_score and keptn_evaluation_result (with the dimension result)
+// - keptn_sli_value, keptn_sli_score and keptn_sli_result for every indicator (with the dimensions indicator and result)
+func qualityGateDataPoints(event cloudevents.Event, data *keptnv2.EvaluationFinishedEventData) []qualityGateDataPoint {
+	keptnContext, _ := event.Context.GetExtension("shkeptncontext")
+
+	// the data points are at the end of the evaluated time range
+	timestamp, err := parseUnixTimestamp(data.Evaluation.TimeEnd)
+	if err != nil {
+		timestamp = event.Time()
+	}
+
+	dimensions := func(extra map[string]string) map[string]string {
+		d := map[string]string{
+			"project":      data.Project,
+			"stage":        data.Stage,
+			"service":      data.Service,
+			"keptnContext": stringExtension(keptnContext),
+		}
+		for k, v := range extra {
+			d[k] = v
+		}
+		return d
+	}
+	point := func(metric string, value float64, extra map[string]string) qualityGateDataPoint {
+		return qualityGateDataPoint{metric: metric, dimensions: dimensions(extra), value: value, timestamp: timestamp}
+	}
+
+	points := []qualityGateDataPoint{
+		point("keptn_evaluation_score", data.Evaluation.Score, nil),
+	}
+	if value, ok := resultValue(data.Evaluation.Result); ok {
+		points = append(points, point("keptn_evaluation_result", value, map[string]string{"result": data.Evaluation.Result}))
+	}
+
+	for _, indicator := range data.Evaluation.IndicatorResults {
+		if indicator == nil || indicator.Value == nil {
+			continue
+		}
+		extra := map[string]string{"indicator": indicator.Value.Metric, "result": indicator.Status}
+
+		// a failed indicator has no value
+		if indicator.Value.Success {
+			points = append(points, point("keptn_sli_value", indicator.Value.Value, extra))
+		}
+		points = append(points, point("keptn_sli_score", indicator.Score, extra))
+		if value, ok := resultValue(indicator.Status); ok {
+			points = append(points, point("keptn_sli_result", value, extra))
+		}
+	}
+
+	return points
+}
+
+// formatPrometheus formats the data points in the Prometheus text format, e.g.,
+// `keptn_evaluation_score{project="sockshop",stage="staging"} 100 1654084800000`
+func formatPrometheus(points []qualityGateDataPoint) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	lines := []string{}
+	for _, p := range points {
+		labels := []string{}
+		for _, key := range sortedDimensionKeys(p.dimensions) {
+			labels = append(labels, fmt.Sprintf(`%s="%s"`, key, escaper.Replace(p.dimensions[key])))
+		}
+		lines = append(lines, fmt.Sprintf("%s{%s} %s %d", p.metric, strings.Join(labels, ","), formatMetricValue(p.value), p.timestamp.UnixMilli()))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// formatCarbon2 formats the data points in the Carbon 2.0 format, e.g.,
+// `metric=keptn_evaluation_score project=sockshop stage=staging 100 1654084800`
+func formatCarbon2(points []qualityGateDataPoint) string {
+	// spaces separate the tags and equal signs separate the key from the value
+	escaper := strings.NewReplacer(" ", "_", "=", "_")
+
+	lines := []string{}
+	for _, p := range points {
+		tags := []string{"metric=" + p.metric}
+		for _, key := range sortedDimensionKeys(p.dimensions) {
+			tags = append(tags, key+"="+escaper.Replace(p.dimensions[key]))
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %d", strings.Join(tags, " "), formatMetricValue(p.value), p.timestamp.Unix()))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// sortedDimensionKeys returns the keys of the dimensions without empty values
+func sortedDimensionKeys(dimensions map[string]string) []string {
+	keys := []string{}
+	for key, value := range dimensions {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sendQualityGateMetrics sends the evaluation as metrics to the HTTP Source in the format (prometheus or carbon2)
+func sendQualityGateMetrics(client *httpSourceClient, format string, event cloudevents.Event, data *keptnv2.EvaluationFinishedEventData) error {
+	points := qualityGateDataPoints(event, data)
+
+	var contentType, body string
+	switch strings.ToLower(format) {
+	case "", metricsFormatPrometheus:
+		contentType, body = httpSourcePrometheusContentType, formatPrometheus(points)
+	case metricsFormatCarbon2:
+		contentType, body = httpSourceCarbon2ContentType, formatCarbon2(points)
+	default:
+		return fmt.Errorf("metrics format should be either `%s` or `%s` but got `%s`", metricsFormatPrometheus, metricsFormatCarbon2, format)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lifecycleEventTimeout)
+	defer cancel()
+
+	return client.send(ctx, contentType, httpSourceMetadata{name: event.Type(), host: ServiceName}, []byte(body))
+}
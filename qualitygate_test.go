@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+func testEvaluationFinishedEvent() (cloudevents.Event, *keptnv2.EvaluationFinishedEventData) {
+	event := cloudevents.NewEvent()
+	event.SetType(keptnv2.GetFinishedEventType(keptnv2.EvaluationTaskName))
+	event.SetExtension("shkeptncontext", "context-1")
+
+	data := &keptnv2.EvaluationFinishedEventData{
+		EventData: keptnv2.EventData{Project: "sockshop", Stage: "staging", Service: "carts"},
+		Evaluation: keptnv2.EvaluationDetails{
+			TimeEnd: "2022-06-01T12:00:00Z",
+			Result:  "warning",
+			Score:   75,
+			IndicatorResults: []*keptnv2.SLIEvaluationResult{
+				{Score: 1, Status: "pass", Value: &keptnv2.SLIResult{Metric: "response_time_p95", Value: 512.5, Success: true}},
+				{Score: 0, Status: "fail", Value: &keptnv2.SLIResult{Metric: "error_rate", Success: false}},
+			},
+		},
+	}
+	return event, data
+}
+
+func TestFormatPrometheus(t *testing.T) {
+	event, data := testEvaluationFinishedEvent()
+	body := formatPrometheus(qualityGateDataPoints(event, data))
+
+	want := []string{
+		`keptn_evaluation_score{keptnContext="context-1",project="sockshop",service="carts",stage="staging"} 75 1654084800000`,
+		`keptn_evaluation_result{keptnContext="context-1",project="sockshop",result="warning",service="carts",stage="staging"} 0.5 1654084800000`,
+		`keptn_sli_value{indicator="response_time_p95",keptnContext="context-1",project="sockshop",result="pass",service="carts",stage="staging"} 512.5 1654084800000`,
+		`keptn_sli_score{indicator="response_time_p95",keptnContext="context-1",project="sockshop",result="pass",service="carts",stage="staging"} 1 1654084800000`,
+		`keptn_sli_result{indicator="response_time_p95",keptnContext="context-1",project="sockshop",result="pass",service="carts",stage="staging"} 1 1654084800000`,
+		`keptn_sli_score{indicator="error_rate",keptnContext="context-1",project="sockshop",result="fail",service="carts",stage="staging"} 0 1654084800000`,
+		`keptn_sli_result{indicator="error_rate",keptnContext="context-1",project="sockshop",result="fail",service="carts",stage="staging"} 0 1654084800000`,
+	}
+	if body != strings.Join(want, "\n")+"\n" {
+		t.Errorf("Unexpected data points:\n%s", body)
+	}
+}
+
+func TestSendQualityGateMetricsCarbon2(t *testing.T) {
+	var contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	event, data := testEvaluationFinishedEvent()
+	if err := sendQualityGateMetrics(newHTTPSourceClient(server.URL), metricsFormatCarbon2, event, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != httpSourceCarbon2ContentType {
+		t.Errorf("Unexpected content type %s", contentType)
+	}
+	first := strings.Split(string(body), "\n")[0]
+	if first != "metric=keptn_evaluation_score keptnContext=context-1 project=sockshop service=carts stage=staging 75 1654084800" {
+		t.Errorf("Unexpected data point %s", first)
+	}
+
+	if err := sendQualityGateMetrics(newHTTPSourceClient(server.URL), "graphite", event, data); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
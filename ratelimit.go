@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// apiQueueLogThreshold is how long a request has to be queued by the limiter to be logged as info (debug otherwise)
+const apiQueueLogThreshold = time.Second
+
+// sumoAPILimiter is shared by all the requests to the Sumo Logic API of the service (metrics queries, search jobs,
+// monitors, dashboards, ...) since Sumo Logic limits the requests per access key.
+// It is set up by _main, the requests aren't limited if it is nil (e.g., in tests).
+var sumoAPILimiter *apiLimiter
+
+// apiLimiter limits the rate of the requests (token bucket) and the number of concurrent requests.
+// Check https://help.sumologic.com/APIs/General-API-Information/API-Rate-Limiting for the limits of Sumo Logic.
+type apiLimiter struct {
+	mu sync.Mutex
+	// rate is the number of requests per second (no limit if 0)
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+
+	// slots has a buffer of the max number of concurrent requests (no limit if nil)
+	slots chan struct{}
+}
+
+func newAPILimiter(rate float64, maxConcurrent int) *apiLimiter {
+	l := &apiLimiter{now: time.Now}
+	if rate > 0 {
+		l.rate = rate
+		l.burst = math.Max(1, math.Ceil(rate))
+		l.tokens = l.burst
+		l.last = l.now()
+	}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a free slot and a token and returns how long the request was queued
+// and the function which frees the slot again
+func (l *apiLimiter) acquire(ctx context.Context) (time.Duration, func(), error) {
+	started := l.now()
+
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return l.now().Sub(started), nil, ctx.Err()
+		}
+		var once sync.Once
+		release = func() {
+			once.Do(func() { <-l.slots })
+		}
+	}
+
+	if wait := l.reserve(); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			l.cancelReservation()
+			release()
+			return l.now().Sub(started), nil, ctx.Err()
+		}
+	}
+
+	return l.now().Sub(started), release, nil
+}
+
+// reserve takes a token from the bucket and returns how long to wait until the token is available
+func (l *apiLimiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	// the tokens go negative for the requests which wait, so that they are served in order
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancelReservation returns the token of a request which stopped waiting
+func (l *apiLimiter) cancelReservation() {
+	if l.rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = math.Min(l.burst, l.tokens+1)
+}
+
+// limitedTransport sends the requests through the limiter (every retry of a request is limited as well)
+type limitedTransport struct {
+	next    http.RoundTripper
+	limiter *apiLimiter
+}
+
+// newLimitedTransport returns the transport for the requests to the Sumo Logic API
+func newLimitedTransport(next http.RoundTripper, limiter *apiLimiter) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if limiter == nil {
+		return next
+	}
+	return &limitedTransport{next: next, limiter: limiter}
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	queued, release, err := t.limiter.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if queued >= apiQueueLogThreshold {
+		log.Infof("Sumo Logic API %s %s was queued for %v by the API rate limit", req.Method, req.URL.Path, queued)
+	} else if queued > 0 {
+		log.Debugf("Sumo Logic API %s %s was queued for %v by the API rate limit", req.Method, req.URL.Path, queued)
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// the request counts as running until its response is read
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// releasingBody frees the slot of the request once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAPILimiterReserve(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := newAPILimiter(4, 0)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// the burst is served right away, the requests after it are spread at the rate
+	for i := 0; i < 4; i++ {
+		if wait := l.reserve(); wait != 0 {
+			t.Errorf("Expected request %d of the burst not to wait, but got %v", i, wait)
+		}
+	}
+	if wait := l.reserve(); wait != 250*time.Millisecond {
+		t.Errorf("Expected the 5th request to wait 250ms, but got %v", wait)
+	}
+	if wait := l.reserve(); wait != 500*time.Millisecond {
+		t.Errorf("Expected the 6th request to wait 500ms, but got %v", wait)
+	}
+
+	// a cancelled request returns its token
+	l.cancelReservation()
+	now = now.Add(time.Second)
+	if wait := l.reserve(); wait != 0 {
+		t.Errorf("Expected the tokens to be refilled after 1s, but got %v", wait)
+	}
+}
+
+func TestLimitedTransportConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newLimitedTransport(nil, newAPILimiter(0, 2))}
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ioutil.ReadAll(res.Body)
+			res.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 2 {
+		t.Errorf("Expected at most 2 concurrent requests, but got %d", maxRunning)
+	}
+}
+
+func TestAPILimiterCancel(t *testing.T) {
+	l := newAPILimiter(0, 1)
+	_, release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.acquire(ctx); err == nil {
+		t.Errorf("Expected the request to give up waiting for a slot")
+	}
+
+	release()
+	release()
+	if _, _, err := l.acquire(context.Background()); err != nil {
+		t.Errorf("Expected the slot to be free again, but got %v", err)
+	}
+}
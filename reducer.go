@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+)
+
+// defaultReducer keeps the behavior of using the first value of the first time series
+const defaultReducer = "first"
+
+const (
+	// histogramReducerPrefix is the prefix of the reducers which compute a percentile from the buckets of a histogram
+	histogramReducerPrefix = "histogram_"
+	// histogramBucketDimension is the dimension with the upper bound of the bucket (as in Prometheus histograms)
+	histogramBucketDimension = "le"
+)
+
+// noDataError is returned when a query returned nothing to reduce to the SLI value
+type noDataError struct {
+	msg string
+}
+
+func (e noDataError) Error() string {
+	return e.msg
+}
+
+// isNoData returns true if the error is (or wraps) a noDataError
+func isNoData(err error) bool {
+	return errors.As(err, &noDataError{})
+}
+
+// reducer reduces a non-empty list of values to a single value
+type reducer struct {
+	name string
+	// points reduces the points of a single time series
+	points func(values []float64) float64
+	// series reduces the values of all time series (after reducing their points)
+	series func(values []float64) float64
+	// histogram reduces the time series of the buckets of a histogram instead (nil for the other reducers)
+	histogram func(timeSeries []types.TimeSeries) (float64, error)
+}
+
+// parseReducer returns the reducer for one of
+// first, last, avg, min, max, sum, count, median, pN (e.g., p95) or histogram_pN (e.g., histogram_p95)
+func parseReducer(name string) (reducer, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = defaultReducer
+	}
+
+	if strings.HasPrefix(name, histogramReducerPrefix) {
+		p, err := parsePercentile(strings.TrimPrefix(name, histogramReducerPrefix))
+		if err != nil {
+			return reducer{}, fmt.Errorf("invalid histogram reducer `%s` (should be between histogram_p0 and histogram_p100)", name)
+		}
+		return reducer{
+			name:      name,
+			histogram: func(timeSeries []types.TimeSeries) (float64, error) { return histogramPercentile(timeSeries, p) },
+		}, nil
+	}
+
+	var fn func(values []float64) float64
+	switch name {
+	case "first":
+		fn = func(values []float64) float64 { return values[0] }
+	case "last":
+		fn = func(values []float64) float64 { return values[len(values)-1] }
+	case "avg":
+		fn = func(values []float64) float64 { return sum(values) / float64(len(values)) }
+	case "min":
+		fn = func(values []float64) float64 {
+			min := values[0]
+			for _, v := range values[1:] {
+				min = math.Min(min, v)
+			}
+			return min
+		}
+	case "max":
+		fn = func(values []float64) float64 {
+			max := values[0]
+			for _, v := range values[1:] {
+				max = math.Max(max, v)
+			}
+			return max
+		}
+	case "sum":
+		fn = sum
+	case "count":
+		// count the points of every time series and add them up
+		// (counting the time series instead wouldn't be of much use)
+		return reducer{
+			name:   name,
+			points: func(values []float64) float64 { return float64(len(values)) },
+			series: sum,
+		}, nil
+	case "median":
+		fn = func(values []float64) float64 { return percentile(values, 50) }
+	default:
+		if !strings.HasPrefix(name, "p") {
+			return reducer{}, fmt.Errorf("unknown reducer `%s` (should be one of first, last, avg, min, max, sum, count, median, pN e.g., p95 or histogram_pN e.g., histogram_p95)", name)
+		}
+		p, err := parsePercentile(name)
+		if err != nil {
+			return reducer{}, fmt.Errorf("invalid percentile reducer `%s` (should be between p0 and p100)", name)
+		}
+		fn = func(values []float64) float64 { return percentile(values, p) }
+	}
+
+	return reducer{name: name, points: fn, series: fn}, nil
+}
+
+// parsePercentile parses pN (e.g., p95) to N
+func parsePercentile(name string) (float64, error) {
+	if !strings.HasPrefix(name, "p") {
+		return 0, fmt.Errorf("percentile `%s` should start with p", name)
+	}
+	p, err := strconv.ParseFloat(name[1:], 64)
+	if err != nil || math.IsNaN(p) || math.IsInf(p, 0) || p < 0 || p > 100 {
+		return 0, fmt.Errorf("percentile `%s` should be between p0 and p100", name)
+	}
+	return p, nil
+}
+
+// reduceTimeSeries reduces the points of every time series and then the values of all time series
+func (r reducer) reduceTimeSeries(timeSeries []types.TimeSeries) (float64, error) {
+	if r.histogram != nil {
+		return r.histogram(timeSeries)
+	}
+
+	seriesValues := []float64{}
+	for _, ts := range timeSeries {
+		if ts.Points == nil || len(ts.Points.Values) == 0 {
+			continue
+		}
+		seriesValues = append(seriesValues, r.points(ts.Points.Values))
+	}
+
+	if len(seriesValues) == 0 {
+		return 0, noDataError{"metrics query returned no data points"}
+	}
+	return r.series(seriesValues), nil
+}
+
+// reduceValues reduces a flat list of values (e.g., the records of a log search)
+func (r reducer) reduceValues(values []float64) (float64, error) {
+	if r.histogram != nil {
+		return 0, fmt.Errorf("reducer `%s` can only be used with metrics queries", r.name)
+	}
+	if len(values) == 0 {
+		return 0, noDataError{"no values to reduce"}
+	}
+	return r.points(values), nil
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// percentile returns the p-th percentile of the values using linear interpolation between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// histogramPercentile returns the p-th percentile of a histogram like histogram_quantile of Prometheus.
+// The query should return a time series per bucket with the upper bound of the bucket in the dimension `le`
+// (e.g., `metric=..._bucket | quantize to 1m using max | rate | sum by le`). The points of every bucket are
+// summed up to the number of observations (or a multiple of it for rates) up to its bound and the percentile
+// is interpolated linearly within the bucket it falls into.
+func histogramPercentile(timeSeries []types.TimeSeries, p float64) (float64, error) {
+	counts := map[float64]float64{}
+	for _, ts := range timeSeries {
+		if ts.Points == nil || len(ts.Points.Values) == 0 {
+			continue
+		}
+		dimensions := map[string]string{}
+		if ts.MetricDefinition != nil {
+			dimensions = ts.MetricDefinition.Dimensions
+		}
+		bound, err := strconv.ParseFloat(dimensions[histogramBucketDimension], 64)
+		if err != nil || math.IsNaN(bound) {
+			return 0, fmt.Errorf("histogram time series without a numeric `%s` dimension: %v", histogramBucketDimension, dimensions)
+		}
+		counts[bound] += sum(ts.Points.Values)
+	}
+
+	if len(counts) == 0 {
+		return 0, noDataError{"metrics query returned no data points"}
+	}
+
+	bounds := make([]float64, 0, len(counts))
+	for bound := range counts {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+	if !math.IsInf(bounds[len(bounds)-1], 1) {
+		return 0, fmt.Errorf("histogram without the `%s=+Inf` bucket", histogramBucketDimension)
+	}
+	if len(bounds) == 1 {
+		return 0, fmt.Errorf("histogram with only the `%s=+Inf` bucket", histogramBucketDimension)
+	}
+
+	// the counts of the buckets are cumulative, which rates don't have to be exactly (e.g., after counter resets)
+	cumulative := make([]float64, len(bounds))
+	for i, bound := range bounds {
+		cumulative[i] = counts[bound]
+		if i > 0 && cumulative[i] < cumulative[i-1] {
+			cumulative[i] = cumulative[i-1]
+		}
+	}
+	total := cumulative[len(cumulative)-1]
+	if total <= 0 {
+		return 0, noDataError{"histogram has no observations"}
+	}
+
+	rank := p / 100 * total
+	i := sort.SearchFloat64s(cumulative, rank)
+	if i == len(bounds)-1 {
+		// the percentile is in the +Inf bucket, so the upper bound of the highest finite bucket is the best estimate
+		return bounds[len(bounds)-2], nil
+	}
+
+	lower, below := 0.0, 0.0
+	if i > 0 {
+		lower, below = bounds[i-1], cumulative[i-1]
+	} else if bounds[0] <= 0 {
+		return bounds[0], nil
+	}
+	if cumulative[i] == below {
+		return bounds[i], nil
+	}
+	return lower + (bounds[i]-lower)*(rank-below)/(cumulative[i]-below), nil
+}
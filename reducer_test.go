@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+)
+
+func TestReducers(t *testing.T) {
+	// one time series per pod
+	timeSeries := []types.TimeSeries{
+		{Points: &types.Points{Values: []float64{1, 2, 3, 4}}},
+		{Points: &types.Points{Values: []float64{10, 20}}},
+		{Points: &types.Points{Values: []float64{}}},
+	}
+
+	tests := []struct {
+		reducer string
+		want    float64
+	}{
+		{"", 1},
+		{"first", 1},
+		{"last", 20},
+		{"avg", 8.75},
+		{"min", 1},
+		{"max", 20},
+		{"sum", 40},
+		{"count", 6},
+		{"median", 8.75},
+		{"p100", 20},
+		{"p0", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reducer, func(t *testing.T) {
+			r, err := parseReducer(tt.reducer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.reduceTimeSeries(timeSeries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("reduceTimeSeries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{15, 20, 35, 40, 50}
+	if got := percentile(values, 50); got != 35 {
+		t.Errorf("percentile(50) = %v, want 35", got)
+	}
+	if got := percentile(values, 90); got != 46 {
+		t.Errorf("percentile(90) = %v, want 46", got)
+	}
+}
+
+func bucketSeries(le string, values ...float64) types.TimeSeries {
+	return types.TimeSeries{
+		MetricDefinition: &types.MetricDefinition{Dimensions: map[string]string{histogramBucketDimension: le}},
+		Points:           &types.Points{Values: values},
+	}
+}
+
+func TestHistogramReducers(t *testing.T) {
+	// the rate of the requests per bucket and minute (out of order like the API can return them)
+	timeSeries := []types.TimeSeries{
+		bucketSeries("50", 3, 3),
+		bucketSeries("10", 1, 1),
+		bucketSeries("+Inf", 5, 5),
+		bucketSeries("100", 4, 4),
+	}
+
+	tests := []struct {
+		reducer string
+		want    float64
+	}{
+		{"histogram_p10", 5},
+		{"histogram_p50", 40},
+		{"histogram_p80", 100},
+		{"histogram_p95", 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reducer, func(t *testing.T) {
+			r, err := parseReducer(tt.reducer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.reduceTimeSeries(timeSeries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("reduceTimeSeries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	r, _ := parseReducer("histogram_p95")
+	if _, err := r.reduceTimeSeries(timeSeries[:2]); err == nil {
+		t.Errorf("Expected an error for a histogram without the +Inf bucket")
+	}
+	if _, err := r.reduceTimeSeries([]types.TimeSeries{{Points: &types.Points{Values: []float64{1}}}}); err == nil {
+		t.Errorf("Expected an error for a time series without the le dimension")
+	}
+	if _, err := r.reduceTimeSeries([]types.TimeSeries{bucketSeries("10", 0), bucketSeries("+Inf", 0)}); !isNoData(err) {
+		t.Errorf("Expected no data for a histogram without observations, but got %v", err)
+	}
+	if _, err := r.reduceValues([]float64{1}); err == nil {
+		t.Errorf("Expected an error for a histogram reducer on log search values")
+	}
+}
+
+func TestParseReducerErrors(t *testing.T) {
+	for _, name := range []string{"mean", "p101", "px", "pnan", "pinf", "p-inf", "histogram_p101", "histogram_avg"} {
+		if _, err := parseReducer(name); err == nil {
+			t.Errorf("Expected an error for reducer %s", name)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryMaxRetries = 4
+	defaultRetryBaseDelay  = 1 * time.Second
+	defaultRetryMaxDelay   = 30 * time.Second
+	defaultRetryBudget     = 2 * time.Minute
+)
+
+// retryPolicy is how often and how long the requests to the Sumo Logic API are retried
+type retryPolicy struct {
+	// MaxRetries is the max number of retries of a request (on top of the first attempt)
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, it doubles with every retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget is the max time a request can take with all its attempts and backoffs
+	Budget time.Duration
+}
+
+// retryTransport retries the requests to the Sumo Logic API which were throttled (429) or failed
+// with a server error (5xx) or a network error. The backoff is exponential with full jitter and
+// the `Retry-After` header of the response takes precedence. Other 4xx responses (e.g., invalid
+// queries or wrong credentials) are returned immediately since retrying doesn't change them.
+type retryTransport struct {
+	next   http.RoundTripper
+	policy retryPolicy
+
+	mu     sync.Mutex
+	random *rand.Rand
+	now    func() time.Time
+}
+
+func newRetryTransport(next http.RoundTripper, policy retryPolicy) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if policy.MaxRetries < 0 {
+		policy.MaxRetries = 0
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultRetryBaseDelay
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	if policy.Budget <= 0 {
+		policy.Budget = defaultRetryBudget
+	}
+	return &retryTransport{
+		next:   next,
+		policy: policy,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:    time.Now,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := t.now()
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		res, err := t.next.RoundTrip(req)
+		if !isRetryable(res, err) || req.Context().Err() != nil {
+			return res, err
+		}
+
+		outcome := ""
+		if err != nil {
+			outcome = err.Error()
+		} else {
+			outcome = res.Status
+		}
+
+		// a request with a body which can't be sent again is not retried
+		if attempt > t.policy.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			log.Warnf("Sumo Logic API %s %s: %s (attempt %d of %d), giving up", req.Method, req.URL.Path, outcome, attempt, t.policy.MaxRetries+1)
+			return res, err
+		}
+
+		delay := t.backoff(attempt, res)
+		if t.now().Sub(started)+delay > t.policy.Budget {
+			log.Warnf("Sumo Logic API %s %s: %s (attempt %d of %d), giving up since the retry budget of %v would be exceeded", req.Method, req.URL.Path, outcome, attempt, t.policy.MaxRetries+1, t.policy.Budget)
+			return res, err
+		}
+		log.Warnf("Sumo Logic API %s %s: %s (attempt %d of %d), retrying in %v", req.Method, req.URL.Path, outcome, attempt, t.policy.MaxRetries+1, delay)
+
+		if res != nil {
+			// the connection can only be reused once the body was read
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, either from `Retry-After` or exponential with full jitter
+func (t *retryTransport) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if delay, ok := parseRetryAfter(res.Header.Get("Retry-After"), t.now()); ok {
+			return delay
+		}
+	}
+
+	max := float64(t.policy.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if max > float64(t.policy.MaxDelay) {
+		max = float64(t.policy.MaxDelay)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Duration(t.random.Float64() * max)
+}
+
+// isRetryable returns true for network errors, throttled requests (429) and server errors (5xx)
+func isRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// parseRetryAfter parses the `Retry-After` header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		status := statuses[len(bodies)-1]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRetryTransport(nil, retryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})}
+	res, err := client.Post(server.URL, "application/json", strings.NewReader(`{"query": "metric=cpu"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || len(bodies) != 3 {
+		t.Errorf("Expected the request to succeed after 3 attempts, but got %s after %d", res.Status, len(bodies))
+	}
+	for _, body := range bodies {
+		if body != `{"query": "metric=cpu"}` {
+			t.Errorf("Expected the body to be sent with every attempt, but got %s", body)
+		}
+	}
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	for name, tc := range map[string]struct {
+		status   int
+		policy   retryPolicy
+		attempts int
+	}{
+		"bad request":   {http.StatusBadRequest, retryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}, 1},
+		"unauthorized":  {http.StatusUnauthorized, retryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}, 1},
+		"max retries":   {http.StatusBadGateway, retryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}, 3},
+		"retry budget":  {http.StatusTooManyRequests, retryPolicy{MaxRetries: 3, BaseDelay: time.Hour, Budget: time.Minute}, 1},
+		"no retries":    {http.StatusInternalServerError, retryPolicy{MaxRetries: 0}, 1},
+		"server errors": {http.StatusGatewayTimeout, retryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.Header().Set("Retry-After", "3600")
+				if tc.status != http.StatusTooManyRequests {
+					w.Header().Del("Retry-After")
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newRetryTransport(nil, tc.policy)}
+			res, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status || attempts != tc.attempts {
+				t.Errorf("Expected %d attempts returning %d, but got %d returning %s", tc.attempts, tc.status, attempts, res.Status)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Wed, 01 Jun 2022 12:00:30 GMT": 30 * time.Second,
+		"Wed, 01 Jun 2022 11:00:00 GMT": 0,
+	} {
+		delay, ok := parseRetryAfter(value, now)
+		if !ok || delay != expected {
+			t.Errorf("Expected %v for %s, but got %v", expected, value, delay)
+		}
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Errorf("Expected an invalid Retry-After to be ignored")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSearchJobTimeout = 5 * time.Minute
+	searchJobPollInterval   = 5 * time.Second
+	// searchJobDeleteTimeout is how long to wait for the deletion of a search job (it can't use the ctx of the search)
+	searchJobDeleteTimeout = 5 * time.Second
+	// searchJobPageSize is the max number of records the Search Job API returns in one request
+	searchJobPageSize = 10000
+
+	searchJobStateDone      = "DONE GATHERING RESULTS"
+	searchJobStateCancelled = "CANCELLED"
+	searchJobTimeFormat     = "2006-01-02T15:04:05"
+)
+
+// searchJobClient runs log searches using the Sumo Logic Search Job API
+// Check https://help.sumologic.com/APIs/Search-Job-API/About-the-Search-Job-API for more info
+type searchJobClient struct {
+	sumoAPIClient
+	deleteTimeout time.Duration
+}
+
+type searchJobRequest struct {
+	Query    string `json:"query"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	TimeZone string `json:"timeZone"`
+}
+
+type searchJobCreated struct {
+	Id string `json:"id"`
+}
+
+type searchJobStatus struct {
+	State         string   `json:"state"`
+	MessageCount  int      `json:"messageCount"`
+	RecordCount   int      `json:"recordCount"`
+	PendingErrors []string `json:"pendingErrors"`
+}
+
+type searchJobRecords struct {
+	Records []struct {
+		Map map[string]string `json:"map"`
+	} `json:"records"`
+}
+
+// newSearchJobClient creates a client for the Search Job API.
+// The Search Job API relies on cookies to route the requests of a job to the same node,
+// that's why every client gets its own cookie jar.
+func newSearchJobClient(basePath, accessId, accessKey string) *searchJobClient {
+	jar, _ := cookiejar.New(nil)
+	return &searchJobClient{
+		sumoAPIClient{
+			basePath:  basePath,
+			accessId:  accessId,
+			accessKey: accessKey,
+			httpClient: &http.Client{
+				Jar:       jar,
+				Transport: newLimitedTransport(http.DefaultTransport, sumoAPILimiter),
+			},
+		},
+		searchJobDeleteTimeout,
+	}
+}
+
+// runSearchJob creates a search job for the time range, waits for it to finish and returns the aggregate records.
+// The search job is deleted once it is done or when ctx is cancelled.
+func (c *searchJobClient) runSearchJob(ctx context.Context, query string, from, to time.Time) ([]map[string]string, error) {
+	created := searchJobCreated{}
+	err := c.do(ctx, http.MethodPost, "/v1/search/jobs", searchJobRequest{
+		Query:    query,
+		From:     from.UTC().Format(searchJobTimeFormat),
+		To:       to.UTC().Format(searchJobTimeFormat),
+		TimeZone: "UTC",
+	}, &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create search job: %w", err)
+	}
+	log.Debugf("created search job %s", created.Id)
+
+	// delete the job even if ctx is already cancelled so that it doesn't keep running in Sumo Logic
+	// (with its own timeout so that a slow Sumo Logic doesn't block the handler)
+	defer func() {
+		deleteCtx, cancel := context.WithTimeout(context.Background(), c.deleteTimeout)
+		defer cancel()
+		if err := c.do(deleteCtx, http.MethodDelete, "/v1/search/jobs/"+created.Id, nil, nil); err != nil {
+			log.Warnf("failed to delete search job %s: %v", created.Id, err)
+		}
+	}()
+
+	status := searchJobStatus{}
+	for {
+		err = c.do(ctx, http.MethodGet, "/v1/search/jobs/"+created.Id, nil, &status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get status of search job %s: %w", created.Id, err)
+		}
+		if len(status.PendingErrors) > 0 {
+			return nil, fmt.Errorf("search job %s failed: %v", created.Id, status.PendingErrors)
+		}
+		if status.State == searchJobStateDone {
+			break
+		}
+		if status.State == searchJobStateCancelled {
+			return nil, fmt.Errorf("search job %s was cancelled", created.Id)
+		}
+
+		log.Debugf("search job %s is in state '%s', polling again in %v", created.Id, status.State, searchJobPollInterval)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("search job %s didn't finish in time: %w", created.Id, ctx.Err())
+		case <-time.After(searchJobPollInterval):
+		}
+	}
+
+	records := []map[string]string{}
+	for offset := 0; offset < status.RecordCount; offset += searchJobPageSize {
+		page := searchJobRecords{}
+		path := fmt.Sprintf("/v1/search/jobs/%s/records?offset=%d&limit=%d", created.Id, offset, searchJobPageSize)
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to get records of search job %s: %w", created.Id, err)
+		}
+		if len(page.Records) == 0 {
+			break
+		}
+		for _, record := range page.Records {
+			records = append(records, record.Map)
+		}
+	}
+
+	return records, nil
+}
+
+// numericFieldValues returns the values of the field in all the records as floats
+func numericFieldValues(records []map[string]string, field string) ([]float64, error) {
+	if len(records) == 0 {
+		return nil, noDataError{"log search returned no records (make sure the query is an aggregate query, e.g., `| count`)"}
+	}
+
+	values := []float64{}
+	for _, record := range records {
+		// Sumo Logic returns the field names in lower case
+		raw, ok := record[field]
+		if !ok {
+			raw, ok = record[strings.ToLower(field)]
+		}
+		if !ok {
+			return nil, fmt.Errorf("field `%s` is not in the log search records", field)
+		}
+
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field `%s` has the non-numeric value '%s'", field, raw)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
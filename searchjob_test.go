@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunSearchJob(t *testing.T) {
+	deleted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/search/jobs":
+			req := searchJobRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Error decoding search job request: %v", err)
+			}
+			if req.From != "2021-01-15T15:04:45" || req.To != "2021-01-15T15:09:45" {
+				t.Errorf("Unexpected time range in search job request: %v - %v", req.From, req.To)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"id": "job-1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/search/jobs/job-1":
+			w.Write([]byte(`{"state": "DONE GATHERING RESULTS", "recordCount": 1}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/search/jobs/job-1/records":
+			w.Write([]byte(`{"records": [{"map": {"_count": "42"}}]}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/search/jobs/job-1":
+			deleted = true
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	from, _ := time.Parse(time.RFC3339, "2021-01-15T15:04:45.000Z")
+	to, _ := time.Parse(time.RFC3339, "2021-01-15T15:09:45.000Z")
+
+	client := newSearchJobClient(server.URL, "id", "key")
+	records, err := client.runSearchJob(context.Background(), "_sourceCategory=prod/carts error | count", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := numericFieldValues(records, "_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values[0] != 42 {
+		t.Errorf("Expected the values to be [42], but got %v", values)
+	}
+	if !deleted {
+		t.Errorf("Expected the search job to be deleted")
+	}
+}
+
+func TestRunSearchJobDeleteTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Write([]byte(`{"id": "job-1"}`))
+		case http.MethodGet:
+			w.Write([]byte(`{"state": "CANCELLED"}`))
+		case http.MethodDelete:
+			// Sumo Logic doesn't answer until the client gives up
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	client := newSearchJobClient(server.URL, "id", "key")
+	client.deleteTimeout = 50 * time.Millisecond
+
+	started := time.Now()
+	if _, err := client.runSearchJob(context.Background(), "error | count", time.Now().Add(-time.Minute), time.Now()); err == nil {
+		t.Errorf("Expected an error for a cancelled search job")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Expected the deletion of the search job to time out, but it took %v", elapsed)
+	}
+}
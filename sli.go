@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	log "github.com/sirupsen/logrus"
+)
+
+// sliLinkLabelPrefix is the prefix of the labels of get-sli.finished with the links to the queries, e.g., `sumologic_throughput`
+const sliLinkLabelPrefix = "sumologic_"
+
+// errIndicatorNotConfigured is the error of indicators requested by the SLO but not defined in sumologic/sli.yaml
+var errIndicatorNotConfigured = errors.New("indicator not configured")
+
+// indicatorResult is the outcome of fetching a single indicator
+type indicatorResult struct {
+	value float64
+	// err is set when the query couldn't be processed, the Sumo Logic API call failed
+	// or the response had no value for the indicator
+	err error
+	// incomplete is set when the deadline passed before the data in Sumo Logic was complete
+	incomplete bool
+	// link is the URL of the query in the Sumo Logic UI (empty if the query couldn't be built)
+	link string
+}
+
+// sliFetcher fetches the SLI values of a single get-sli.triggered event from Sumo Logic
+type sliFetcher struct {
+	// ctx is done once the time budget of the event runs out
+	ctx   context.Context
+	data  *keptnv2.GetSLITriggeredEventData
+	start time.Time
+	end   time.Time
+
+	metricsClient metricsQueryRunner
+	searchClient  *searchJobClient
+
+	// dataDeadline is the point in time until which we wait for the metrics data to be complete
+	dataDeadline time.Time
+	// dataTimeout is how long after `end` an empty metrics response is final
+	dataTimeout   time.Duration
+	pollInterval  time.Duration
+	searchTimeout time.Duration
+
+	// appendCustomFilters is the default for indicators that don't set appendCustomFilters
+	appendCustomFilters bool
+	// uiURL is the URL of the Sumo Logic UI for the links to the queries
+	uiURL string
+}
+
+// fetch gets the value of a single indicator (or its noDataValue if the query returned nothing)
+func (f *sliFetcher) fetch(indicatorName string, indicator indicatorConfig) indicatorResult {
+	res := f.fetchIndicator(indicatorName, indicator)
+	if res.err != nil && indicator.NoDataValue != nil && isNoData(res.err) {
+		log.Debugf("indicator %s has no data (%v), using the noDataValue %v", indicatorName, res.err, *indicator.NoDataValue)
+		return indicatorResult{value: *indicator.NoDataValue, incomplete: res.incomplete, link: res.link}
+	}
+	return res
+}
+
+func (f *sliFetcher) fetchIndicator(indicatorName string, indicator indicatorConfig) indicatorResult {
+	r, err := parseReducer(indicator.Reducer)
+	if err != nil {
+		return indicatorResult{err: err}
+	}
+
+	appendFilters := f.appendCustomFilters
+	if indicator.AppendCustomFilters != nil {
+		appendFilters = *indicator.AppendCustomFilters
+	}
+	filters := f.data.GetSLI.CustomFilters
+
+	if indicator.Type == indicatorTypeLogs {
+		query, err := replaceQueryParameters(f.data, indicator.Query, f.start, f.end)
+		if err != nil {
+			return indicatorResult{err: fmt.Errorf("invalid query: %w in query `%s`", err, indicator.Query)}
+		}
+		if appendFilters {
+			query = appendCustomFiltersToLogQuery(query, filters)
+		}
+		log.Debugf("indicator: %v, query: %v, from: %v, to: %v", indicatorName, query, f.start.Unix(), f.end.Unix())
+		res := f.fetchLogs(query, indicator.Field, r)
+		res.link = logSearchURL(f.uiURL, query, f.start, f.end)
+		return res
+	}
+
+	rows, valueRow := indicator.metricsRows()
+	queries := make(map[string]string, len(rows))
+	for id, query := range rows {
+		expanded, err := replaceQueryParameters(f.data, query, f.start, f.end)
+		if err != nil {
+			return indicatorResult{err: fmt.Errorf("invalid query: %w in query `%s`", err, query)}
+		}
+		queries[id] = expanded
+		if appendFilters {
+			withFilters, err := appendCustomFiltersToMetricsQuery(queries[id], filters)
+			if err != nil {
+				return indicatorResult{err: fmt.Errorf("invalid query: %w in query `%s`", err, queries[id])}
+			}
+			queries[id] = withFilters
+		}
+		log.Debugf("indicator: %v, row: %v, query: %v, from: %v, to: %v", indicatorName, id, queries[id], f.start.Unix(), f.end.Unix())
+	}
+	res := f.fetchMetrics(queries, valueRow, r)
+	res.link = f.metricsLink(queries)
+	return res
+}
+
+// metricsLink returns the link to the metrics query rows in the Metrics Explorer. The rows are linked as written
+// (with `quantize`, `timeshift` and the emulated operators) since the Metrics Explorer supports all of them.
+func (f *sliFetcher) metricsLink(queries map[string]string) string {
+	ids := make([]string, 0, len(queries))
+	for id := range queries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rows := make([]string, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, queries[id])
+	}
+	return metricsExplorerURL(f.uiURL, rows, f.start, f.end)
+}
+
+// fetchMetrics runs the metrics queries (by row id) using the Metrics Query API and reduces the value row to the SLI value.
+// The other rows are sent as transient rows so that only the value row is returned.
+func (f *sliFetcher) fetchMetrics(queries map[string]string, valueRow string, r reducer) indicatorResult {
+	ids := make([]string, 0, len(queries))
+	for id := range queries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var timeshift time.Duration
+	var clientOperators []clientOperator
+	rows := []types.MetricsQueryRow{}
+	for i, id := range ids {
+		processed, err := processQuery(queries[id])
+		if err != nil {
+			return indicatorResult{err: fmt.Errorf("invalid query: %w", err)}
+		}
+
+		// the time range is shared by all the rows, so they can't be shifted differently
+		if i > 0 && processed.Timeshift != timeshift {
+			return indicatorResult{err: fmt.Errorf("invalid query: row %s uses a different `timeshift` than row %s (all rows should use the same `timeshift`)", id, ids[0])}
+		}
+		timeshift = processed.Timeshift
+
+		// the emulated operators only change the returned rows, not what the other rows refer to
+		if len(processed.ClientOperators) > 0 && id != valueRow {
+			return indicatorResult{err: fmt.Errorf("invalid query: `fillmissing` and `outlier` can only be used in the value row %s but row %s uses them", valueRow, id)}
+		}
+		if id == valueRow {
+			clientOperators = processed.ClientOperators
+		}
+
+		rows = append(rows, types.MetricsQueryRow{
+			Query:        processed.Query,
+			RowId:        id,
+			Quantization: processed.Quantization,
+			Rollup:       processed.Rollup,
+			Transient:    id != valueRow,
+		})
+		log.Debugf("formattedQuery (row %v): %v", id, processed.Query)
+	}
+
+	// `timeshift` is emulated by shifting the time range of the query into the past
+	from := f.start.Add(-timeshift)
+	to := f.end.Add(-timeshift)
+
+	req := types.MetricsQueryRequest{
+		Queries:   rows,
+		TimeRange: newTimeRange(from, to),
+	}
+	log.Debugf("metrics query request: %v", req)
+	mRes, hRes, complete, err := runMetricsQueryUntilComplete(f.ctx, f.metricsClient, req, to, f.dataDeadline, f.dataTimeout, f.pollInterval)
+	log.Debugf("metrics query response: %v", mRes)
+	if hRes != nil {
+		log.Debugf("http response: %v", *hRes)
+	}
+	if err != nil {
+		return indicatorResult{err: fmt.Errorf("metrics query failed: %w", err)}
+	}
+
+	for _, op := range clientOperators {
+		applyClientOperator(mRes, valueRow, op, from, to)
+	}
+
+	value, err := reduceMetricsResponse(mRes, valueRow, r)
+	if err != nil {
+		return indicatorResult{err: err, incomplete: !complete}
+	}
+	log.Debugf("metric value from sumologic: %v", value)
+
+	return indicatorResult{value: value, incomplete: !complete}
+}
+
+// findRow returns the time series of the row in the response (nil if the response has no such row)
+func findRow(mRes types.MetricsQueryResponse, rowID string) *types.TimeSeriesList {
+	for _, row := range mRes.QueryResult {
+		if row.RowId == rowID {
+			return row.TimeSeriesList
+		}
+	}
+	return nil
+}
+
+// applyClientOperator applies the emulated operator to the time series of the row in the response
+func applyClientOperator(mRes types.MetricsQueryResponse, rowID string, op clientOperator, from, to time.Time) {
+	list := findRow(mRes, rowID)
+	if list == nil {
+		return
+	}
+	list.TimeSeries = op.apply(list.TimeSeries, from, to)
+}
+
+// reduceMetricsResponse reduces the time series of the row in the response to a single value
+func reduceMetricsResponse(mRes types.MetricsQueryResponse, rowID string, r reducer) (float64, error) {
+	list := findRow(mRes, rowID)
+	if list == nil {
+		return 0, noDataError{fmt.Sprintf("metrics query returned no result for row %s", rowID)}
+	}
+
+	timeSeries := list.TimeSeries
+	if len(timeSeries) == 0 {
+		return 0, noDataError{"metrics query returned no time series"}
+	}
+
+	return r.reduceTimeSeries(timeSeries)
+}
+
+// fetchLogs runs the log search using the Search Job API and reduces the values of field in the records to the SLI value
+func (f *sliFetcher) fetchLogs(query string, field string, r reducer) indicatorResult {
+	ctx, cancel := context.WithTimeout(f.ctx, f.searchTimeout)
+	defer cancel()
+
+	records, err := f.searchClient.runSearchJob(ctx, query, f.start, f.end)
+	if err != nil {
+		return indicatorResult{err: err}
+	}
+	log.Debugf("log search records: %v", records)
+
+	values, err := numericFieldValues(records, field)
+	if err != nil {
+		return indicatorResult{err: err}
+	}
+
+	value, err := r.reduceValues(values)
+	if err != nil {
+		return indicatorResult{err: err}
+	}
+
+	return indicatorResult{value: value}
+}
+
+// isUnfinished returns true if the indicator couldn't be fetched because the time budget ran out (or the event was cancelled)
+func isUnfinished(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// unfinishedIndicators returns the indicators which weren't fetched before the time budget ran out
+func unfinishedIndicators(indicators []string, results []indicatorResult) []string {
+	unfinished := []string{}
+	for i, res := range results {
+		if isUnfinished(res.err) {
+			unfinished = append(unfinished, indicators[i])
+		}
+	}
+	return unfinished
+}
+
+// sliLinkLabels returns a label per indicator with the link to its query in the Sumo Logic UI
+func sliLinkLabels(indicators []string, results []indicatorResult) map[string]string {
+	labels := map[string]string{}
+	for i, res := range results {
+		if res.link != "" {
+			labels[sliLinkLabelPrefix+indicators[i]] = res.link
+		}
+	}
+	return labels
+}
+
+// toSLIResults converts the results of the indicators into SLI results and determines
+// the status, result and message of the get-sli.finished event:
+// - all indicators succeeded: succeeded/pass
+// - some indicators failed: succeeded/warning
+// - all indicators failed: errored/fail
+//
+// Indicators that are not configured count as failed but are listed separately in the message.
+func toSLIResults(indicators []string, results []indicatorResult) ([]*keptnv2.SLIResult, keptnv2.StatusType, keptnv2.ResultType, string) {
+	sliResults := []*keptnv2.SLIResult{}
+	failed := []string{}
+	notConfigured := []string{}
+	incomplete := []string{}
+
+	for i, res := range results {
+		sliResult := &keptnv2.SLIResult{
+			Metric:  indicators[i],
+			Value:   res.value,
+			Success: res.err == nil,
+		}
+
+		if res.err != nil {
+			sliResult.Message = res.err.Error()
+			if errors.Is(res.err, errIndicatorNotConfigured) {
+				notConfigured = append(notConfigured, indicators[i])
+			} else {
+				failed = append(failed, indicators[i])
+			}
+			log.Errorf("failed to fetch indicator %s: %v", indicators[i], res.err)
+		} else if res.incomplete {
+			sliResult.Message = "data in Sumo Logic was still incomplete when the deadline passed"
+			incomplete = append(incomplete, indicators[i])
+		}
+
+		sliResults = append(sliResults, sliResult)
+	}
+
+	messages := []string{}
+	if len(failed) > 0 {
+		messages = append(messages, fmt.Sprintf("failed to fetch indicators: %s", strings.Join(failed, ", ")))
+	}
+	if len(notConfigured) > 0 {
+		messages = append(messages, fmt.Sprintf("indicators not configured in %s: %s", sliFile, strings.Join(notConfigured, ", ")))
+	}
+	if len(incomplete) > 0 {
+		messages = append(messages, fmt.Sprintf("data in Sumo Logic was still incomplete when the deadline passed for indicators: %s", strings.Join(incomplete, ", ")))
+	}
+	message := strings.Join(messages, "; ")
+
+	switch unsuccessful := len(failed) + len(notConfigured); {
+	case unsuccessful == 0:
+		return sliResults, keptnv2.StatusSucceeded, keptnv2.ResultPass, message
+	case unsuccessful < len(results):
+		return sliResults, keptnv2.StatusSucceeded, keptnv2.ResultWarning, message
+	default:
+		return sliResults, keptnv2.StatusErrored, keptnv2.ResultFailed, message
+	}
+}
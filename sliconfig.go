@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	indicatorTypeMetrics = "metrics"
+	indicatorTypeLogs    = "logs"
+
+	// defaultLogsField is the field used as the SLI value for log searches
+	// when the indicator doesn't specify one (the default output field of `count`)
+	defaultLogsField = "_count"
+
+	// defaultRowID is the row id of an indicator with a single metrics query
+	defaultRowID = "A"
+)
+
+// indicatorConfig is the definition of a single SLI in sumologic/sli.yaml.
+// An indicator is either just a metrics query
+//
+//	cpu_usage: "metric=container_cpu_usage_seconds_total service=$SERVICE | quantize to $DURATION using avg"
+//
+// or an object which can also describe a log search
+//
+//	error_count:
+//	  type: logs
+//	  query: "_sourceCategory=prod/carts error | count"
+//	  field: _count
+//	cpu_usage_max:
+//	  query: "metric=container_cpu_usage_seconds_total service=$SERVICE | quantize to 1m using avg"
+//	  reducer: max
+//
+// or several metrics query rows where one of them is the SLI value
+//
+//	error_rate:
+//	  rows:
+//	    A: "metric=istio_requests_total destination_workload=$SERVICE response_code=5* | quantize to 1m using sum | sum"
+//	    B: "metric=istio_requests_total destination_workload=$SERVICE | quantize to 1m using sum | sum"
+//	    C: "#A / #B * 100"
+//	  value: C
+type indicatorConfig struct {
+	// Type is either `metrics` (default) or `logs`
+	Type string `yaml:"type"`
+	// Query is the Sumo Logic metrics query or log search query
+	Query string `yaml:"query"`
+	// Field is the numeric field of the aggregate records used as the SLI value (only used for `logs`)
+	Field string `yaml:"field"`
+	// Reducer turns the points of all the time series (or the values of all the log search records)
+	// into the SLI value, e.g., `avg`, `max`, `p95` or `histogram_p95` (check parseReducer)
+	Reducer string `yaml:"reducer"`
+	// Rows are the metrics queries by row id (A to Z) which can refer to each other, e.g., `#A / #B` (only used for `metrics` instead of Query)
+	Rows map[string]string `yaml:"rows"`
+	// Value is the id of the row used as the SLI value (can be left out if there is only 1 row)
+	Value string `yaml:"value"`
+	// AppendCustomFilters overrides APPEND_CUSTOM_FILTERS for the indicator
+	AppendCustomFilters *bool `yaml:"appendCustomFilters"`
+	// NoDataValue is the SLI value when the query returns no data (e.g., 0 for error counts), the indicator fails if not set
+	NoDataValue *float64 `yaml:"noDataValue"`
+}
+
+// UnmarshalYAML supports both the plain query string and the object form of an indicator
+func (c *indicatorConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*c = indicatorConfig{Type: indicatorTypeMetrics, Query: value.Value}
+		return nil
+	}
+
+	// plainIndicatorConfig has no UnmarshalYAML method so we don't end up calling this method again
+	type plainIndicatorConfig indicatorConfig
+	plain := plainIndicatorConfig{}
+	if err := value.Decode(&plain); err != nil {
+		return err
+	}
+	*c = indicatorConfig(plain)
+
+	c.Type = strings.ToLower(strings.TrimSpace(c.Type))
+	switch c.Type {
+	case "":
+		c.Type = indicatorTypeMetrics
+	case indicatorTypeMetrics:
+	case indicatorTypeLogs:
+		if c.Field == "" {
+			c.Field = defaultLogsField
+		}
+	default:
+		return errors.New("indicator type should be either `" + indicatorTypeMetrics + "` or `" + indicatorTypeLogs + "` but got `" + c.Type + "`")
+	}
+
+	if err := c.validateRows(); err != nil {
+		return err
+	}
+
+	r, err := parseReducer(c.Reducer)
+	if err != nil {
+		return err
+	}
+	if r.histogram != nil && c.Type != indicatorTypeMetrics {
+		return errors.New("`" + r.name + "` can only be used with metrics queries")
+	}
+	return nil
+}
+
+// validateRows checks that an indicator has either a query or rows and that the value row is one of the rows
+func (c *indicatorConfig) validateRows() error {
+	if len(c.Rows) == 0 {
+		if c.Value != "" {
+			return errors.New("`value` can only be used together with `rows`")
+		}
+		return nil
+	}
+
+	if c.Type != indicatorTypeMetrics {
+		return errors.New("`rows` can only be used with metrics queries")
+	}
+	if c.Query != "" {
+		return errors.New("an indicator should have either `query` or `rows` but not both")
+	}
+
+	for id := range c.Rows {
+		if !isRowID(id) {
+			return fmt.Errorf("invalid row id `%s` (should be a letter from A to Z)", id)
+		}
+	}
+
+	if c.Value == "" {
+		if len(c.Rows) > 1 {
+			return errors.New("`value` is required to pick the row used as the SLI value when there are several rows")
+		}
+		for id := range c.Rows {
+			c.Value = id
+		}
+	}
+	if _, ok := c.Rows[c.Value]; !ok {
+		return fmt.Errorf("value row `%s` is not one of the rows", c.Value)
+	}
+
+	return nil
+}
+
+// isRowID returns true for the row ids the Metrics Query API accepts in expressions like `#A / #B`
+// (no package-level regexp since defaultIndicators is parsed during package initialization,
+// which can run before such a variable is initialized as the dependency through UnmarshalYAML isn't visible to Go)
+func isRowID(id string) bool {
+	return len(id) == 1 && id[0] >= 'A' && id[0] <= 'Z'
+}
+
+// metricsRows returns the metrics queries by row id and the id of the row used as the SLI value
+func (c indicatorConfig) metricsRows() (map[string]string, string) {
+	if len(c.Rows) == 0 {
+		return map[string]string{defaultRowID: c.Query}, defaultRowID
+	}
+	return c.Rows, c.Value
+}
+
+// sliConfigNodes is sumologic/sli.yaml with the indicators left as YAML nodes so they can be merged across the levels
+type sliConfigNodes struct {
+	Indicators map[string]yaml.Node `yaml:"indicators"`
+}
+
+// sliConfigLevel is one of the levels sumologic/sli.yaml is read from
+type sliConfigLevel struct {
+	name  string
+	fetch func() (*models.Resource, error)
+}
+
+// getSLIConfiguration retrieves the SLI configuration for a service by merging the SLI configuration on
+// project, stage and service level (in this order of increasing precedence):
+// - an indicator defined on a single level is taken as is
+// - an indicator defined on several levels is merged field by field, e.g., a service can override only the
+// reducer of an indicator defined on project level; setting `query` drops inherited `rows`/`value` and vice versa
+// - an indicator given as a plain query string replaces the indicator of the levels before
+//
+// A missing file is fine on every level. Indicators which aren't defined on any level are not in the result.
+func getSLIConfiguration(myKeptn *keptnv2.Keptn, project string, stage string, service string, resourceURI string) (map[string]indicatorConfig, error) {
+	levels := []sliConfigLevel{}
+	if project != "" {
+		levels = append(levels, sliConfigLevel{"project", func() (*models.Resource, error) {
+			return myKeptn.ResourceHandler.GetProjectResource(project, resourceURI)
+		}})
+	}
+	if project != "" && stage != "" {
+		levels = append(levels, sliConfigLevel{"stage", func() (*models.Resource, error) {
+			return myKeptn.ResourceHandler.GetStageResource(project, stage, resourceURI)
+		}})
+	}
+	if project != "" && stage != "" && service != "" {
+		levels = append(levels, sliConfigLevel{"service", func() (*models.Resource, error) {
+			return myKeptn.ResourceHandler.GetServiceResource(project, stage, service, resourceURI)
+		}})
+	}
+
+	merged := map[string]*yaml.Node{}
+	origins := map[string][]string{}
+	found := false
+	for _, level := range levels {
+		res, err := level.fetch()
+		if err != nil && !isResourceNotFound(err) {
+			return nil, err
+		}
+		if res == nil {
+			continue
+		}
+		found = true
+
+		config := sliConfigNodes{}
+		if err := yaml.Unmarshal([]byte(res.ResourceContent), &config); err != nil {
+			return nil, fmt.Errorf("invalid %s on %s level: %w", resourceURI, level.name, err)
+		}
+
+		for name := range config.Indicators {
+			node := config.Indicators[name]
+			merged[name] = mergeIndicatorNodes(merged[name], &node)
+			origins[name] = append(origins[name], level.name)
+		}
+	}
+
+	if found && len(merged) == 0 {
+		return nil, errors.New("missing required field: indicators")
+	}
+
+	indicators := make(map[string]indicatorConfig, len(merged))
+	for name, node := range merged {
+		indicator := indicatorConfig{}
+		if err := node.Decode(&indicator); err != nil {
+			return nil, fmt.Errorf("invalid indicator %s (defined on %s level): %w", name, strings.Join(origins[name], ", "), err)
+		}
+		log.Debugf("indicator %s is defined on %s level", name, strings.Join(origins[name], ", "))
+		indicators[name] = indicator
+	}
+
+	return indicators, nil
+}
+
+// isResourceNotFound returns true if the configuration service doesn't have the file
+func isResourceNotFound(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "resource not found")
+}
+
+// mergeIndicatorNodes merges the definition of an indicator on a level into the definition of the levels before
+func mergeIndicatorNodes(base *yaml.Node, override *yaml.Node) *yaml.Node {
+	if base == nil || override.Kind != yaml.MappingNode {
+		return override
+	}
+	if base.Kind == yaml.ScalarNode {
+		// a plain query string is the same as an object with just the query
+		base = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Tag:     "!!map",
+			Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: "query"}, base},
+		}
+	}
+	if base.Kind != yaml.MappingNode {
+		return override
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	merged.Content = append(merged.Content, base.Content...)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key := override.Content[i].Value
+		switch key {
+		case "query":
+			merged.Content = removeMappingKeys(merged.Content, "rows", "value")
+		case "rows":
+			merged.Content = removeMappingKeys(merged.Content, "query")
+		}
+		merged.Content = append(removeMappingKeys(merged.Content, key), override.Content[i], override.Content[i+1])
+	}
+	return merged
+}
+
+// removeMappingKeys removes the keys (and their values) from the content of a mapping node
+func removeMappingKeys(content []*yaml.Node, keys ...string) []*yaml.Node {
+	kept := []*yaml.Node{}
+	for i := 0; i+1 < len(content); i += 2 {
+		remove := false
+		for _, key := range keys {
+			if content[i].Value == key {
+				remove = true
+			}
+		}
+		if !remove {
+			kept = append(kept, content[i], content[i+1])
+		}
+	}
+	return kept
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	api "github.com/keptn/go-utils/pkg/api/utils"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"gopkg.in/yaml.v3"
+)
+
+// testSLIConfig is a single level of sumologic/sli.yaml
+type testSLIConfig struct {
+	Indicators map[string]indicatorConfig `yaml:"indicators"`
+}
+
+func TestIndicatorConfigRows(t *testing.T) {
+	config := testSLIConfig{}
+	err := yaml.Unmarshal([]byte(`
+indicators:
+  throughput: "metric=requests | quantize to 1m using sum | sum"
+  error_rate:
+    rows:
+      A: "metric=requests code=5* | sum"
+      B: "metric=requests | sum"
+      C: "#A / #B * 100"
+    value: C
+  single_row:
+    rows:
+      B: "metric=requests | sum"
+`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, value := config.Indicators["throughput"].metricsRows()
+	if value != defaultRowID || rows[defaultRowID] != "metric=requests | quantize to 1m using sum | sum" {
+		t.Errorf("Expected the query in row %s, but got %v (value %s)", defaultRowID, rows, value)
+	}
+	rows, value = config.Indicators["error_rate"].metricsRows()
+	if value != "C" || len(rows) != 3 {
+		t.Errorf("Expected 3 rows with value C, but got %v (value %s)", rows, value)
+	}
+	if _, value = config.Indicators["single_row"].metricsRows(); value != "B" {
+		t.Errorf("Expected the only row to be the value, but got %s", value)
+	}
+
+	for _, invalid := range []string{
+		"indicators:\n  x:\n    rows:\n      A: metric=a\n      B: metric=b\n",
+		"indicators:\n  x:\n    rows:\n      A: metric=a\n    value: B\n",
+		"indicators:\n  x:\n    rows:\n      a: metric=a\n",
+		"indicators:\n  x:\n    query: metric=a\n    rows:\n      A: metric=a\n",
+		"indicators:\n  x:\n    type: logs\n    rows:\n      A: error\n",
+		"indicators:\n  x:\n    query: metric=a\n    value: A\n",
+		"indicators:\n  x:\n    type: logs\n    query: error | count\n    reducer: histogram_p95\n",
+	} {
+		if err := yaml.Unmarshal([]byte(invalid), &testSLIConfig{}); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+// newFakeConfigurationService serves the files by path (e.g., `/v1/project/sockshop/resource/sumologic/sli.yaml`)
+// like the configuration service and returns 404 for the other files
+func newFakeConfigurationService(t *testing.T, files map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, err := url.PathUnescape(r.URL.EscapedPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, ok := files[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		resource := models.Resource{ResourceContent: base64.StdEncoding.EncodeToString([]byte(content))}
+		if err := json.NewEncoder(w).Encode(resource); err != nil {
+			t.Fatal(err)
+		}
+	}))
+}
+
+func TestGetSLIConfiguration(t *testing.T) {
+	server := newFakeConfigurationService(t, map[string]string{
+		"/v1/project/sockshop/resource/" + sliFile: `
+indicators:
+  throughput: "metric=requests | sum"
+  error_rate:
+    rows:
+      A: "metric=requests code=5* | sum"
+      B: "metric=requests | sum"
+      C: "#A / #B"
+    value: C
+  cpu_usage:
+    query: "metric=cpu | avg"
+    reducer: max
+`,
+		"/v1/project/sockshop/stage/staging/resource/" + sliFile: `
+indicators:
+  cpu_usage:
+    reducer: p95
+  error_rate:
+    query: "metric=errors | sum"
+`,
+		"/v1/project/sockshop/stage/staging/service/carts/resource/" + sliFile: `
+indicators:
+  throughput:
+    reducer: avg
+  memory_usage: "metric=memory | avg"
+`,
+	})
+	defer server.Close()
+
+	myKeptn := &keptnv2.Keptn{}
+	myKeptn.ResourceHandler = api.NewResourceHandler(server.URL)
+
+	indicators, err := getSLIConfiguration(myKeptn, "sockshop", "staging", "carts", sliFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := indicators["throughput"]; got.Query != "metric=requests | sum" || got.Reducer != "avg" {
+		t.Errorf("Expected the query from project level and the reducer from service level, but got %+v", got)
+	}
+	if got := indicators["cpu_usage"]; got.Query != "metric=cpu | avg" || got.Reducer != "p95" {
+		t.Errorf("Expected the reducer from stage level to override project level, but got %+v", got)
+	}
+	if got := indicators["error_rate"]; got.Query != "metric=errors | sum" || len(got.Rows) != 0 || got.Value != "" {
+		t.Errorf("Expected the query from stage level to replace the rows from project level, but got %+v", got)
+	}
+	if got := indicators["memory_usage"]; got.Query != "metric=memory | avg" {
+		t.Errorf("Expected the indicator defined only on service level, but got %+v", got)
+	}
+	if _, ok := indicators["response_time_p95"]; ok {
+		t.Errorf("Expected no indicator which isn't defined on any level")
+	}
+
+	// nothing on any level is fine, the indicators are reported as not configured
+	indicators, err = getSLIConfiguration(myKeptn, "other-project", "staging", "carts", sliFile)
+	if err != nil || len(indicators) != 0 {
+		t.Errorf("Expected no indicators and no error, but got %v and %v", indicators, err)
+	}
+}
+
+func TestGetSLIConfigurationInvalidIndicator(t *testing.T) {
+	server := newFakeConfigurationService(t, map[string]string{
+		"/v1/project/sockshop/resource/" + sliFile:               "indicators:\n  throughput:\n    query: metric=requests\n",
+		"/v1/project/sockshop/stage/staging/resource/" + sliFile: "indicators:\n  throughput:\n    type: traces\n",
+	})
+	defer server.Close()
+
+	myKeptn := &keptnv2.Keptn{}
+	myKeptn.ResourceHandler = api.NewResourceHandler(server.URL)
+
+	_, err := getSLIConfiguration(myKeptn, "sockshop", "staging", "carts", sliFile)
+	if err == nil || !strings.Contains(err.Error(), "project, stage level") {
+		t.Errorf("Expected an error naming the levels of the invalid indicator, but got %v", err)
+	}
+}
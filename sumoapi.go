@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sumoAPIClient calls the Sumo Logic APIs the SDK doesn't cover (or doesn't cover completely) with plain HTTP requests
+type sumoAPIClient struct {
+	basePath   string
+	accessId   string
+	accessKey  string
+	httpClient *http.Client
+}
+
+// sumoUIURL returns the URL of the Sumo Logic UI of the API endpoint's deployment,
+// e.g., https://service.eu.sumologic.com for https://api.eu.sumologic.com/api
+func sumoUIURL(apiEndpoint string) string {
+	u, err := url.Parse(apiEndpoint)
+	if err != nil || u.Host == "" {
+		return "https://service.sumologic.com"
+	}
+	if strings.HasPrefix(u.Host, "api.") {
+		u.Host = "service." + strings.TrimPrefix(u.Host, "api.")
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// logSearchURL returns the link to the log search in the Sumo Logic UI
+func logSearchURL(uiURL, query string, from, to time.Time) string {
+	return fmt.Sprintf("%s/ui/#/search/create?query=%s&startTime=%d&endTime=%d", uiURL, url.QueryEscape(query), from.UnixMilli(), to.UnixMilli())
+}
+
+// metricsExplorerURL returns the link to the metrics queries in the Metrics Explorer of the Sumo Logic UI
+// (one `query` parameter per row in the order of the rows)
+func metricsExplorerURL(uiURL string, queries []string, from, to time.Time) string {
+	params := ""
+	for _, query := range queries {
+		params += "query=" + url.QueryEscape(query) + "&"
+	}
+	return fmt.Sprintf("%s/ui/#/metrics?%sstartTime=%d&endTime=%d", uiURL, params, from.UnixMilli(), to.UnixMilli())
+}
+
+// do sends a request to the Sumo Logic API and decodes the JSON response into out (if it is not nil)
+func (c *sumoAPIClient) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.basePath+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.accessId, c.accessKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s: %s", method, path, res.Status, string(resBody))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resBody, out)
+}
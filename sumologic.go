@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDataReadinessTimeout = 5 * time.Minute
+	defaultDataPollInterval     = 15 * time.Second
+	defaultGetSLITimeout        = 10 * time.Minute
+	// dataCompletenessTolerance is how far before `end` the last bucket (or data point without quantization)
+	// can end for the data to count as complete
+	dataCompletenessTolerance = time.Minute
+	// dataSettlingDelay is how long after `end` Sumo Logic needs to ingest the data of the last bucket
+	// (a bucket which covers `end` can still be partial before)
+	dataSettlingDelay = 2 * time.Minute
+)
+
+// contextTransport sends the requests with ctx (the requests of the SDK client have no context),
+// so that the requests of a get-sli event are cancelled once its time budget runs out
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// metricsQueryRunner is the part of the Sumo Logic API client used to run metrics queries
+type metricsQueryRunner interface {
+	RunMetricsQueries(body types.MetricsQueryRequest) (types.MetricsQueryResponse, *http.Response, error)
+}
+
+// newTimeRange creates the time range for the Metrics Query API
+func newTimeRange(from, to time.Time) *types.ResolvableTimeRange {
+	return &types.ResolvableTimeRange{
+		Type_: "BeginBoundedTimeRange",
+		From: types.TimeRangeBoundary{
+			Type:        "EpochTimeRangeBoundary",
+			EpochMillis: from.UnixMilli(),
+			RangeName:   "from",
+		},
+		To: types.TimeRangeBoundary{
+			Type:        "EpochTimeRangeBoundary",
+			EpochMillis: to.UnixMilli(),
+			RangeName:   "to",
+		},
+	}
+}
+
+// runMetricsQueryUntilComplete runs the metrics query until every returned time series
+// has a data point at or near `end` or until the deadline passes.
+// Sumo Logic takes some time to ingest the data, so querying right after `end`
+// returns incomplete time series.
+// An empty response is final once `end` is older than dataTimeout since the data of a query without matching
+// time series (e.g., a typo in a metric name) would have been ingested by then, otherwise it is polled until the deadline.
+// It returns the last response and whether the data in it is complete (or an error once ctx is done).
+func runMetricsQueryUntilComplete(ctx context.Context, client metricsQueryRunner, req types.MetricsQueryRequest, end time.Time, deadline time.Time, dataTimeout time.Duration, pollInterval time.Duration) (types.MetricsQueryResponse, *http.Response, bool, error) {
+	var quantization int64
+	for _, row := range req.Queries {
+		if row.Quantization > quantization {
+			quantization = row.Quantization
+		}
+	}
+
+	for {
+		mRes, hRes, err := client.RunMetricsQueries(req)
+		if err != nil {
+			return mRes, hRes, false, err
+		}
+
+		if isMetricsDataComplete(mRes, end, time.Duration(quantization)*time.Millisecond, time.Now()) {
+			return mRes, hRes, true, nil
+		}
+
+		if !hasTimeSeries(mRes) && time.Since(end) > dataTimeout {
+			return mRes, hRes, true, nil
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return mRes, hRes, false, nil
+		}
+
+		log.Debugf("metrics data is not complete yet, polling again in %v", pollInterval)
+		select {
+		case <-ctx.Done():
+			return mRes, hRes, false, fmt.Errorf("stopped waiting for complete metrics data: %w", ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// hasTimeSeries returns true if any row of the response has a time series
+func hasTimeSeries(mRes types.MetricsQueryResponse) bool {
+	for _, row := range mRes.QueryResult {
+		if row.TimeSeriesList != nil && len(row.TimeSeriesList.TimeSeries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isMetricsDataComplete checks that `end` is at least dataSettlingDelay ago, that the response has
+// at least one time series and that the last bucket of every time series ends at or near `end`.
+// The timestamps of the points are the starts of their quantization buckets.
+func isMetricsDataComplete(mRes types.MetricsQueryResponse, end time.Time, quantization time.Duration, now time.Time) bool {
+	if now.Before(end.Add(dataSettlingDelay)) {
+		return false
+	}
+	threshold := end.Add(-dataCompletenessTolerance).UnixMilli()
+
+	found := false
+	for _, row := range mRes.QueryResult {
+		if row.TimeSeriesList == nil {
+			continue
+		}
+		for _, ts := range row.TimeSeriesList.TimeSeries {
+			if ts.Points == nil || len(ts.Points.Timestamps) == 0 {
+				return false
+			}
+			if ts.Points.Timestamps[len(ts.Points.Timestamps)-1]+quantization.Milliseconds() < threshold {
+				return false
+			}
+			found = true
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SumoLogic-Labs/sumologic-go-sdk/service/cip/types"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+// fakeMetricsQueryRunner returns the responses one after the other
+// (the last response is repeated once all of them are used)
+type fakeMetricsQueryRunner struct {
+	responses []types.MetricsQueryResponse
+	calls     int
+	requests  []types.MetricsQueryRequest
+}
+
+func (f *fakeMetricsQueryRunner) RunMetricsQueries(body types.MetricsQueryRequest) (types.MetricsQueryResponse, *http.Response, error) {
+	f.requests = append(f.requests, body)
+	i := f.calls
+	if i >= len(f.responses) {
+		i = len(f.responses) - 1
+	}
+	f.calls++
+	return f.responses[i], &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func metricsResponse(timestamps ...int64) types.MetricsQueryResponse {
+	values := make([]float64, len(timestamps))
+	return types.MetricsQueryResponse{
+		QueryResult: []types.TimeSeriesRow{
+			{
+				RowId: "A",
+				TimeSeriesList: &types.TimeSeriesList{
+					TimeSeries: []types.TimeSeries{
+						{Points: &types.Points{Timestamps: timestamps, Values: values}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestIsMetricsDataComplete(t *testing.T) {
+	end := time.Unix(1000, 0)
+	settled := end.Add(dataSettlingDelay)
+
+	tests := []struct {
+		name         string
+		res          types.MetricsQueryResponse
+		quantization time.Duration
+		now          time.Time
+		want         bool
+	}{
+		{"no series", types.MetricsQueryResponse{}, 0, settled, false},
+		{"last point at end", metricsResponse(end.Add(-2*time.Minute).UnixMilli(), end.UnixMilli()), 0, settled, true},
+		{"last point too early", metricsResponse(end.Add(-2 * time.Minute).UnixMilli()), 0, settled, false},
+		{"last bucket ends at end", metricsResponse(end.Add(-5 * time.Minute).UnixMilli()), 5 * time.Minute, settled, true},
+		{"last bucket ends too early", metricsResponse(end.Add(-10 * time.Minute).UnixMilli()), 5 * time.Minute, settled, false},
+		{"last bucket not settled", metricsResponse(end.Add(-5 * time.Minute).UnixMilli()), 5 * time.Minute, end.Add(time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMetricsDataComplete(tt.res, end, tt.quantization, tt.now); got != tt.want {
+				t.Errorf("isMetricsDataComplete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunMetricsQueryUntilComplete(t *testing.T) {
+	end := time.Now().Add(-dataSettlingDelay)
+	runner := &fakeMetricsQueryRunner{
+		responses: []types.MetricsQueryResponse{
+			metricsResponse(end.Add(-5 * time.Minute).UnixMilli()),
+			metricsResponse(end.Add(-5*time.Minute).UnixMilli(), end.UnixMilli()),
+		},
+	}
+
+	_, _, complete, err := runMetricsQueryUntilComplete(context.Background(), runner, types.MetricsQueryRequest{}, end, time.Now().Add(time.Second), time.Hour, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !complete || runner.calls != 2 {
+		t.Errorf("Expected complete data after 2 calls, but got complete=%v after %d calls", complete, runner.calls)
+	}
+
+	runner = &fakeMetricsQueryRunner{
+		responses: []types.MetricsQueryResponse{metricsResponse(end.Add(-5 * time.Minute).UnixMilli())},
+	}
+	_, _, complete, err = runMetricsQueryUntilComplete(context.Background(), runner, types.MetricsQueryRequest{}, end, time.Now().Add(20*time.Millisecond), time.Hour, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if complete {
+		t.Errorf("Expected incomplete data once the deadline passed")
+	}
+
+	// an empty response is polled until the deadline while the data can still be ingested
+	empty := &fakeMetricsQueryRunner{responses: []types.MetricsQueryResponse{{}}}
+	_, _, complete, err = runMetricsQueryUntilComplete(context.Background(), empty, types.MetricsQueryRequest{}, end, time.Now().Add(20*time.Millisecond), 5*time.Minute, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if complete || empty.calls < 2 {
+		t.Errorf("Expected an empty response to be polled until the deadline, but got complete=%v after %d calls", complete, empty.calls)
+	}
+
+	// and it is final once the end of the evaluation is older than the data readiness timeout
+	empty = &fakeMetricsQueryRunner{responses: []types.MetricsQueryResponse{{}}}
+	_, _, complete, err = runMetricsQueryUntilComplete(context.Background(), empty, types.MetricsQueryRequest{}, end.Add(-5*time.Minute), time.Now().Add(time.Hour), 5*time.Minute, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !complete || empty.calls != 1 {
+		t.Errorf("Expected an empty response to be final after 1 call, but got complete=%v after %d calls", complete, empty.calls)
+	}
+
+	// the time budget of the event stops the polling before the data deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, _, err = runMetricsQueryUntilComplete(ctx, runner, types.MetricsQueryRequest{}, end, time.Now().Add(time.Hour), time.Hour, time.Millisecond)
+	if !isUnfinished(err) {
+		t.Errorf("Expected the polling to stop once ctx is done, but got %v", err)
+	}
+}
+
+func TestToSLIResults(t *testing.T) {
+	indicators := []string{"throughput", "error_rate", "response_time_p95"}
+
+	sliResults, status, result, message := toSLIResults(indicators, []indicatorResult{
+		{value: 10},
+		{err: errors.New("metrics query returned no time series")},
+		{value: 300, incomplete: true},
+	})
+
+	if len(sliResults) != len(indicators) {
+		t.Fatalf("Expected a result for every indicator, but got %d", len(sliResults))
+	}
+	if !sliResults[0].Success || sliResults[0].Value != 10 {
+		t.Errorf("Expected throughput to succeed with value 10, but got %+v", sliResults[0])
+	}
+	if sliResults[1].Success || sliResults[1].Message != "metrics query returned no time series" {
+		t.Errorf("Expected error_rate to fail with the error as message, but got %+v", sliResults[1])
+	}
+	if !sliResults[2].Success || sliResults[2].Message == "" {
+		t.Errorf("Expected response_time_p95 to succeed with a message about incomplete data, but got %+v", sliResults[2])
+	}
+	if status != keptnv2.StatusSucceeded || result != keptnv2.ResultWarning {
+		t.Errorf("Expected succeeded/warning, but got %s/%s", status, result)
+	}
+	if message == "" {
+		t.Errorf("Expected a message about the failed and incomplete indicators")
+	}
+
+	_, status, result, message = toSLIResults(indicators[:2], []indicatorResult{
+		{value: 10},
+		{err: fmt.Errorf("%w: error_rate is not defined", errIndicatorNotConfigured)},
+	})
+	if status != keptnv2.StatusSucceeded || result != keptnv2.ResultWarning || !strings.Contains(message, "not configured in "+sliFile+": error_rate") {
+		t.Errorf("Expected succeeded/warning with error_rate listed as not configured, but got %s/%s %q", status, result, message)
+	}
+
+	_, status, result, _ = toSLIResults(indicators[:1], []indicatorResult{{err: errors.New("invalid query")}})
+	if status != keptnv2.StatusErrored || result != keptnv2.ResultFailed {
+		t.Errorf("Expected errored/fail when all indicators failed, but got %s/%s", status, result)
+	}
+}
+
+func TestFetchMetricsWithRows(t *testing.T) {
+	end := time.Now()
+	client := &fakeMetricsQueryRunner{
+		responses: []types.MetricsQueryResponse{
+			{
+				QueryResult: []types.TimeSeriesRow{
+					{
+						RowId: "C",
+						TimeSeriesList: &types.TimeSeriesList{
+							TimeSeries: []types.TimeSeries{
+								{Points: &types.Points{Timestamps: []int64{end.UnixMilli()}, Values: []float64{2.5}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	fetcher := &sliFetcher{ctx: context.Background(), start: end.Add(-5 * time.Minute), end: end, metricsClient: client, dataDeadline: end}
+	r, _ := parseReducer("")
+
+	res := fetcher.fetchMetrics(map[string]string{
+		"A": "metric=requests code=5* | quantize to 1m using sum | sum",
+		"B": "metric=requests | quantize to 1m using sum | sum",
+		"C": "#A / #B * 100",
+	}, "C", r)
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.value != 2.5 {
+		t.Errorf("Expected the value of row C, but got %v", res.value)
+	}
+
+	rows := client.requests[0].Queries
+	if len(rows) != 3 || rows[0].RowId != "A" || rows[2].RowId != "C" {
+		t.Fatalf("Expected rows A, B and C, but got %+v", rows)
+	}
+	if !rows[0].Transient || !rows[1].Transient || rows[2].Transient {
+		t.Errorf("Expected only the value row C to be returned, but got %+v", rows)
+	}
+	if rows[0].Quantization != time.Minute.Milliseconds() || rows[0].Rollup != "Sum" || rows[0].Query != "metric=requests code=5* | sum" {
+		t.Errorf("Expected quantize to be set on row A, but got %+v", rows[0])
+	}
+
+	for _, queries := range []map[string]string{
+		{"A": "metric=requests | timeshift 1d", "B": "metric=requests", "C": "#A / #B"},
+		{"A": "metric=requests | quantize to 1m | fillmissing using zero", "B": "metric=requests", "C": "#A / #B"},
+	} {
+		if res := fetcher.fetchMetrics(queries, "C", r); res.err == nil {
+			t.Errorf("Expected an error for %v", queries)
+		}
+	}
+}
+
+func TestFetchNoDataValue(t *testing.T) {
+	end := time.Now()
+	client := &fakeMetricsQueryRunner{responses: []types.MetricsQueryResponse{{}}}
+	fetcher := &sliFetcher{
+		ctx:           context.Background(),
+		data:          &keptnv2.GetSLITriggeredEventData{},
+		start:         end.Add(-5 * time.Minute),
+		end:           end,
+		metricsClient: client,
+		dataDeadline:  end,
+	}
+
+	res := fetcher.fetch("error_count", indicatorConfig{Type: indicatorTypeMetrics, Query: "metric=errors | sum"})
+	if res.err == nil || !isNoData(res.err) {
+		t.Errorf("Expected a no data error, but got %v", res.err)
+	}
+
+	zero := 0.0
+	res = fetcher.fetch("error_count", indicatorConfig{Type: indicatorTypeMetrics, Query: "metric=errors | sum", NoDataValue: &zero})
+	if res.err != nil || res.value != 0 {
+		t.Errorf("Expected the noDataValue 0, but got %v (%v)", res.value, res.err)
+	}
+}
+
+func TestSLILinkLabels(t *testing.T) {
+	end := time.Unix(1654084800, 0)
+	client := &fakeMetricsQueryRunner{responses: []types.MetricsQueryResponse{metricsResponse(end.UnixMilli())}}
+	fetcher := &sliFetcher{
+		ctx:           context.Background(),
+		data:          &keptnv2.GetSLITriggeredEventData{EventData: keptnv2.EventData{Service: "carts"}},
+		start:         end.Add(-5 * time.Minute),
+		end:           end,
+		metricsClient: client,
+		dataDeadline:  end,
+		uiURL:         "https://service.eu.sumologic.com",
+	}
+
+	zero := 0.0
+	res := fetcher.fetch("throughput", indicatorConfig{Type: indicatorTypeMetrics, Query: "metric=requests service=$SERVICE | quantize to 1m using sum | timeshift 1d", NoDataValue: &zero})
+	expected := "https://service.eu.sumologic.com/ui/#/metrics?query=metric%3Drequests+service%3Dcarts+%7C+quantize+to+1m+using+sum+%7C+timeshift+1d&startTime=1654084500000&endTime=1654084800000"
+	if res.link != expected {
+		t.Errorf("Expected the link %s, but got %s", expected, res.link)
+	}
+
+	labels := sliLinkLabels([]string{"throughput", "not_configured"}, []indicatorResult{res, {err: errIndicatorNotConfigured}})
+	if len(labels) != 1 || labels["sumologic_throughput"] != expected {
+		t.Errorf("Expected a label for throughput only, but got %v", labels)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/keptn/go-utils/pkg/lib/keptn"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// webhookPath is the path of the endpoint which receives the notifications of Sumo Logic webhook connections
+	webhookPath = "/sumologic/webhook"
+
+	defaultWebhookDedupWindow = 30 * time.Minute
+	// webhookMaxBodySize is the max size of a notification (Sumo Logic limits the payload of a webhook to 100KB)
+	webhookMaxBodySize = 100 * 1024
+
+	// remediationTaskName is the task of the remediation sequence, e.g., sh.keptn.event.production.remediation.triggered
+	remediationTaskName = "remediation"
+
+	// resolvedTriggerPrefix is the prefix of the trigger types which resolve an alert, e.g., ResolvedCritical
+	resolvedTriggerPrefix = "Resolved"
+
+	webhookStatusTriggered = "triggered"
+	webhookStatusDuplicate = "duplicate"
+	webhookStatusResolved  = "resolved"
+	webhookStatusIgnored   = "ignored"
+)
+
+// sumoWebhookPayload is the payload of the webhook connection (check the README for the payload template).
+// All the fields are strings since they are rendered from the webhook variables of Sumo Logic.
+// Check https://help.sumologic.com/Manage/Connections-and-Integrations/Webhook-Connections/Set_Up_Webhook_Connections#webhook-payload-variables
+type sumoWebhookPayload struct {
+	// Id is the id of the monitor ({{Id}})
+	Id string `json:"id"`
+	// Name is the name of the monitor ({{Name}})
+	Name string `json:"name"`
+	// Description is the description of the monitor ({{Description}}) with the project, stage and service
+	// of the monitors created by configure-monitoring
+	Description string `json:"description"`
+	// TriggerType is either of Critical, Warning, MissingData, ResolvedCritical, ResolvedWarning and ResolvedMissingData ({{TriggerType}})
+	TriggerType      string `json:"triggerType"`
+	TriggerCondition string `json:"triggerCondition"`
+	TriggerValue     string `json:"triggerValue"`
+	TriggerTime      string `json:"triggerTime"`
+	QueryURL         string `json:"queryUrl"`
+	AlertResponseURL string `json:"alertResponseUrl"`
+
+	// Project, Stage and Service override the ones in the description (for monitors not created by configure-monitoring)
+	Project string `json:"project"`
+	Stage   string `json:"stage"`
+	Service string `json:"service"`
+	// ProblemTitle overrides the name of the monitor as the problem title (which is matched by remediation.yaml)
+	ProblemTitle string `json:"problemTitle"`
+}
+
+// remediationTriggeredEventData is the data of the remediation sequence triggered by an alert
+type remediationTriggeredEventData struct {
+	keptnv2.EventData
+	Problem keptnv2.ProblemDetails `json:"problem"`
+}
+
+// webhookResponse is the response of the webhook endpoint
+type webhookResponse struct {
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+	KeptnContext string `json:"keptnContext,omitempty"`
+}
+
+// webhookHandler turns the notifications of Sumo Logic monitors into Keptn remediation sequences.
+// A notification for an alert which already triggered a remediation within the dedup window is dropped
+// (until the alert is resolved).
+type webhookHandler struct {
+	token       string
+	dedupWindow time.Duration
+	sender      keptn.EventSender
+
+	mu sync.Mutex
+	// triggered is when a remediation was triggered by alert key (check alertKey)
+	triggered map[string]time.Time
+	now       func() time.Time
+}
+
+func newWebhookHandler(token string, dedupWindow time.Duration, sender keptn.EventSender) *webhookHandler {
+	if dedupWindow <= 0 {
+		dedupWindow = defaultWebhookDedupWindow
+	}
+	return &webhookHandler{
+		token:       token,
+		dedupWindow: dedupWindow,
+		sender:      sender,
+		triggered:   map[string]time.Time{},
+		now:         time.Now,
+	}
+}
+
+// middleware serves the webhook endpoint and passes all the other requests to the CloudEvents handler
+func (h *webhookHandler) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == webhookPath {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeWebhookResponse(w, http.StatusMethodNotAllowed, webhookResponse{Status: "error", Message: "only POST is supported"})
+		return
+	}
+	if !h.authorized(r) {
+		writeWebhookResponse(w, http.StatusUnauthorized, webhookResponse{Status: "error", Message: "invalid or missing token"})
+		return
+	}
+
+	payload := sumoWebhookPayload{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookMaxBodySize)).Decode(&payload); err != nil {
+		writeWebhookResponse(w, http.StatusBadRequest, webhookResponse{Status: "error", Message: fmt.Sprintf("invalid payload: %v", err)})
+		return
+	}
+
+	res, err := h.handle(r.Context(), payload)
+	if err != nil {
+		log.Errorf("failed to handle the notification of monitor %s: %v", payload.Name, err)
+		writeWebhookResponse(w, http.StatusBadGateway, webhookResponse{Status: "error", Message: err.Error()})
+		return
+	}
+	writeWebhookResponse(w, http.StatusOK, res)
+}
+
+// authorized checks the token in the `Authorization: Bearer <token>` header.
+// Without a configured token every request is rejected since the notifications trigger remediations.
+func (h *webhookHandler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// handle triggers a remediation sequence for an alert (unless it is a duplicate) and forgets resolved alerts
+func (h *webhookHandler) handle(ctx context.Context, payload sumoWebhookPayload) (webhookResponse, error) {
+	project, stage, service, ok := payload.keptnService()
+	if !ok {
+		msg := fmt.Sprintf("can't find the project, stage and service of monitor %s (set `project`, `stage` and `service` in the payload)", payload.Name)
+		log.Warn(msg)
+		return webhookResponse{Status: webhookStatusIgnored, Message: msg}, nil
+	}
+
+	if strings.HasPrefix(payload.TriggerType, resolvedTriggerPrefix) {
+		resolved := payload
+		resolved.TriggerType = strings.TrimPrefix(payload.TriggerType, resolvedTriggerPrefix)
+		h.release(alertKey(resolved))
+		log.Infof("alert %s of monitor %s is resolved", payload.TriggerType, payload.Name)
+		return webhookResponse{Status: webhookStatusResolved}, nil
+	}
+
+	key := alertKey(payload)
+	if !h.claim(key) {
+		log.Infof("dropping repeated notification %s of monitor %s", payload.TriggerType, payload.Name)
+		return webhookResponse{Status: webhookStatusDuplicate}, nil
+	}
+
+	keptnContext := uuid.NewString()
+	event, err := keptnv2.KeptnEvent(keptnv2.GetTriggeredEventType(stage+"."+remediationTaskName), ServiceName, remediationTriggeredEventData{
+		EventData: keptnv2.EventData{
+			Project: project,
+			Stage:   stage,
+			Service: service,
+			Labels:  payload.labels(),
+		},
+		Problem: keptnv2.ProblemDetails{
+			ProblemTitle: payload.problemTitle(),
+			RootCause:    payload.rootCause(),
+		},
+	}).WithKeptnContext(keptnContext).Build()
+	if err == nil {
+		err = h.sender.Send(ctx, keptnv2.ToCloudEvent(event))
+	}
+	if err != nil {
+		// a failed notification can be retried by Sumo Logic
+		h.release(key)
+		return webhookResponse{}, fmt.Errorf("failed to trigger remediation: %w", err)
+	}
+
+	log.Infof("triggered remediation %s in %s/%s/%s for %s of monitor %s", keptnContext, project, stage, service, payload.TriggerType, payload.Name)
+	return webhookResponse{Status: webhookStatusTriggered, KeptnContext: keptnContext}, nil
+}
+
+// claim returns true if no remediation was triggered for the alert within the dedup window and remembers the alert
+func (h *webhookHandler) claim(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	for k, t := range h.triggered {
+		if now.Sub(t) >= h.dedupWindow {
+			delete(h.triggered, k)
+		}
+	}
+
+	if _, ok := h.triggered[key]; ok {
+		return false
+	}
+	h.triggered[key] = now
+	return true
+}
+
+func (h *webhookHandler) release(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.triggered, key)
+}
+
+// alertKey identifies the notifications of the same alert, e.g., `<monitor id>/Critical`
+func alertKey(payload sumoWebhookPayload) string {
+	id := payload.Id
+	if id == "" {
+		id = payload.Name
+	}
+	return id + "/" + payload.TriggerType
+}
+
+// keptnService returns the project, stage and service of the alert from the payload or the monitor description
+func (p sumoWebhookPayload) keptnService() (string, string, string, bool) {
+	project, stage, service := p.Project, p.Stage, p.Service
+	if match := monitorDescriptionPattern.FindStringSubmatch(p.Description); match != nil {
+		if project == "" {
+			project = match[1]
+		}
+		if stage == "" {
+			stage = match[2]
+		}
+		if service == "" {
+			service = match[3]
+		}
+	}
+	return project, stage, service, project != "" && stage != "" && service != ""
+}
+
+func (p sumoWebhookPayload) problemTitle() string {
+	if p.ProblemTitle != "" {
+		return p.ProblemTitle
+	}
+	return p.Name
+}
+
+func (p sumoWebhookPayload) rootCause() string {
+	cause := fmt.Sprintf("Sumo Logic monitor %s triggered %s", p.Name, p.TriggerType)
+	if p.TriggerCondition != "" {
+		cause += fmt.Sprintf(" (%s", p.TriggerCondition)
+		if p.TriggerValue != "" {
+			cause += fmt.Sprintf(", value %s", p.TriggerValue)
+		}
+		cause += ")"
+	}
+	return cause
+}
+
+// labels are shown with the remediation in the Keptn Bridge
+func (p sumoWebhookPayload) labels() map[string]string {
+	labels := map[string]string{}
+	for name, value := range map[string]string{
+		monitorIdLabel:         p.Id,
+		"sumologicTriggerType": p.TriggerType,
+		triggerTimeLabel:       p.TriggerTime,
+		"sumologicQuery":       p.QueryURL,
+		alertURLLabel:          p.AlertResponseURL,
+	} {
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+func writeWebhookResponse(w http.ResponseWriter, statusCode int, res webhookResponse) {
+	body, _ := json.Marshal(res)
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(statusCode)
+
+	_, err := w.Write(body)
+	if err != nil {
+		log.Println(err)
+	}
+}
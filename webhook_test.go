@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keptn/go-utils/pkg/lib/v0_2_0/fake"
+)
+
+func postWebhook(t *testing.T, h http.Handler, token string, payload sumoWebhookPayload) (int, webhookResponse) {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, webhookPath, strings.NewReader(string(body)))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	res := webhookResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %s: %v", rec.Body.String(), err)
+	}
+	return rec.Code, res
+}
+
+func TestWebhookTriggersRemediation(t *testing.T) {
+	sender := &fake.EventSender{}
+	h := newWebhookHandler("secret", 10*time.Minute, sender)
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	alert := sumoWebhookPayload{
+		Id:               "0000000000A1B2C3",
+		Name:             "carts production: response_time_p95",
+		Description:      monitorDescription("sockshop", "production", "carts"),
+		TriggerType:      "Critical",
+		TriggerCondition: "greater than 1000",
+		TriggerValue:     "1234.5",
+	}
+
+	if code, _ := postWebhook(t, h, "wrong", alert); code != http.StatusUnauthorized {
+		t.Errorf("Expected an invalid token to be rejected, but got %d", code)
+	}
+
+	code, res := postWebhook(t, h, "secret", alert)
+	if code != http.StatusOK || res.Status != webhookStatusTriggered || res.KeptnContext == "" {
+		t.Fatalf("Expected a remediation to be triggered, but got %d %+v", code, res)
+	}
+	if len(sender.SentEvents) != 1 || sender.SentEvents[0].Type() != "sh.keptn.event.production.remediation.triggered" {
+		t.Fatalf("Expected a remediation.triggered event in production, but got %v", sender.SentEvents)
+	}
+	data := remediationTriggeredEventData{}
+	if err := sender.SentEvents[0].DataAs(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Project != "sockshop" || data.Stage != "production" || data.Service != "carts" || data.Problem.ProblemTitle != alert.Name {
+		t.Errorf("Unexpected remediation %+v", data)
+	}
+	if data.Labels["sumologicMonitorId"] != alert.Id || !strings.Contains(data.Problem.RootCause, "1234.5") {
+		t.Errorf("Expected the alert details in the remediation, but got %+v", data)
+	}
+
+	// repeated notifications are dropped until the alert is resolved or the dedup window is over
+	if _, res = postWebhook(t, h, "secret", alert); res.Status != webhookStatusDuplicate {
+		t.Errorf("Expected a duplicate, but got %+v", res)
+	}
+	resolved := alert
+	resolved.TriggerType = "ResolvedCritical"
+	if _, res = postWebhook(t, h, "secret", resolved); res.Status != webhookStatusResolved {
+		t.Errorf("Expected the alert to be resolved, but got %+v", res)
+	}
+	if _, res = postWebhook(t, h, "secret", alert); res.Status != webhookStatusTriggered {
+		t.Errorf("Expected a new remediation after the alert was resolved, but got %+v", res)
+	}
+	now = now.Add(10 * time.Minute)
+	if _, res = postWebhook(t, h, "secret", alert); res.Status != webhookStatusTriggered {
+		t.Errorf("Expected a new remediation after the dedup window, but got %+v", res)
+	}
+	if len(sender.SentEvents) != 3 {
+		t.Errorf("Expected 3 remediations to be triggered, but got %d", len(sender.SentEvents))
+	}
+}
+
+func TestWebhookKeptnService(t *testing.T) {
+	unknown := sumoWebhookPayload{Name: "Some other monitor", Description: "not created by Keptn", TriggerType: "Critical"}
+	if _, _, _, ok := unknown.keptnService(); ok {
+		t.Errorf("Expected no project, stage and service for a monitor not created by Keptn")
+	}
+
+	explicit := unknown
+	explicit.Project, explicit.Stage, explicit.Service = "sockshop", "staging", "orders"
+	project, stage, service, ok := explicit.keptnService()
+	if !ok || project != "sockshop" || stage != "staging" || service != "orders" {
+		t.Errorf("Expected the project, stage and service of the payload, but got %s/%s/%s", project, stage, service)
+	}
+
+	h := newWebhookHandler("secret", 0, &fake.EventSender{})
+	if _, res := postWebhook(t, h, "secret", unknown); res.Status != webhookStatusIgnored {
+		t.Errorf("Expected the notification to be ignored, but got %+v", res)
+	}
+}
+
+func TestWebhookWithoutToken(t *testing.T) {
+	sender := &fake.EventSender{}
+	h := newWebhookHandler("", 0, sender)
+
+	payload := sumoWebhookPayload{Id: "monitor-1", Name: "High latency", TriggerType: "Critical", Project: "sockshop", Stage: "production", Service: "carts"}
+	for _, token := range []string{"", "anything"} {
+		if code, _ := postWebhook(t, h, token, payload); code != http.StatusUnauthorized {
+			t.Errorf("Expected every notification to be rejected without a configured token, but got %d", code)
+		}
+	}
+	if len(sender.SentEvents) != 0 {
+		t.Errorf("Expected no remediation to be triggered, but got %d events", len(sender.SentEvents))
+	}
+}